@@ -0,0 +1,283 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jelinden/rssfetcher/app/jobs"
+	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/store"
+	"github.com/jelinden/rssfetcher/app/webhook"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const batchSize = 500
+
+// ValidationError reports an invalid field in an admin operation.
+type ValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *ValidationError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Service holds the admin operations shared by the HTML and JSON handlers.
+type Service struct {
+	Store store.Store
+	Jobs  *jobs.Runner
+	// Webhooks retries failed deliveries, nil when webhooks are not sent.
+	Webhooks *webhook.Dispatcher
+}
+
+func New(s store.Store, j *jobs.Runner) *Service {
+	return &Service{Store: s, Jobs: j}
+}
+
+// CreateCategory saves a new category with a unique name.
+func (a *Service) CreateCategory(ctx context.Context, category rss.Category) (rss.Category, error) {
+	category.Name = strings.TrimSpace(category.Name)
+	if category.Name == "" {
+		return category, &ValidationError{Field: "categoryName", Message: "is required"}
+	}
+	if _, err := a.Store.GetCategory(ctx, category.Name); err == nil {
+		return category, store.ErrDuplicate
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return category, err
+	}
+	category.ID = primitive.NewObjectID()
+	return category, a.Store.SaveCategory(ctx, category)
+}
+
+// UpdateCategory saves an edited category and starts a job copying it into
+// the feeds and items that embed it.
+func (a *Service) UpdateCategory(ctx context.Context, category rss.Category) (jobs.Job, error) {
+	category.Name = strings.TrimSpace(category.Name)
+	if category.Name == "" {
+		return jobs.Job{}, &ValidationError{Field: "categoryName", Message: "is required"}
+	}
+	if _, err := a.Store.GetCategoryByID(ctx, category.ID); err != nil {
+		return jobs.Job{}, err
+	}
+	if err := a.Store.UpdateCategory(ctx, category); err != nil {
+		return jobs.Job{}, err
+	}
+	return a.reassignCategory(ctx, category.ID, category, "updating category "+category.Name, nil)
+}
+
+// CreateSubCategory saves a new subcategory with a unique name under an
+// existing category.
+func (a *Service) CreateSubCategory(ctx context.Context, subCategory rss.SubCategory) (rss.SubCategory, error) {
+	subCategory.SubCategory = strings.TrimSpace(subCategory.SubCategory)
+	if err := a.validateSubCategory(ctx, subCategory); err != nil {
+		return subCategory, err
+	}
+	if _, err := a.Store.GetSubCategory(ctx, subCategory.SubCategory); err == nil {
+		return subCategory, store.ErrDuplicate
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return subCategory, err
+	}
+	subCategory.ID = primitive.NewObjectID()
+	return subCategory, a.Store.SaveSubCategory(ctx, subCategory)
+}
+
+// UpdateSubCategory saves an edited subcategory and starts a job copying it
+// into the feeds and items that embed it.
+func (a *Service) UpdateSubCategory(ctx context.Context, subCategory rss.SubCategory) (jobs.Job, error) {
+	subCategory.SubCategory = strings.TrimSpace(subCategory.SubCategory)
+	if err := a.validateSubCategory(ctx, subCategory); err != nil {
+		return jobs.Job{}, err
+	}
+	if _, err := a.Store.GetSubCategoryByID(ctx, subCategory.ID); err != nil {
+		return jobs.Job{}, err
+	}
+	if err := a.Store.UpdateSubCategory(ctx, subCategory); err != nil {
+		return jobs.Job{}, err
+	}
+	return a.reassignSubCategory(ctx, subCategory.ID, subCategory, "updating subcategory "+subCategory.SubCategory, nil)
+}
+
+func (a *Service) validateSubCategory(ctx context.Context, subCategory rss.SubCategory) error {
+	if subCategory.SubCategory == "" {
+		return &ValidationError{Field: "subCategory", Message: "is required"}
+	}
+	if subCategory.ParentID.IsZero() {
+		return &ValidationError{Field: "parentId", Message: "is required"}
+	}
+	_, err := a.Store.GetCategoryByID(ctx, subCategory.ParentID)
+	if errors.Is(err, store.ErrNotFound) {
+		return &ValidationError{Field: "parentId", Message: "does not exist"}
+	}
+	return err
+}
+
+// CheckFeedCategories fails unless the feed's subcategory, when it has one,
+// belongs to the feed's category.
+func CheckFeedCategories(category rss.Category, subCategory *rss.SubCategory) error {
+	if subCategory == nil || subCategory.ID.IsZero() {
+		return nil
+	}
+	if subCategory.ParentID != category.ID {
+		return &ValidationError{Field: "subCategory",
+			Message: fmt.Sprintf("%s is not a sub category of %s", subCategory.SubCategory, category.Name)}
+	}
+	return nil
+}
+
+// DeleteCategory removes a category. Feeds, items and subcategories still
+// in it are moved to the target category first, so a target is required
+// while there are any. The returned job is zero when nothing had to be
+// moved.
+func (a *Service) DeleteCategory(ctx context.Context, id, target primitive.ObjectID) (jobs.Job, error) {
+	category, err := a.Store.GetCategoryByID(ctx, id)
+	if err != nil {
+		return jobs.Job{}, err
+	}
+	children, err := a.children(ctx, id)
+	if err != nil {
+		return jobs.Job{}, err
+	}
+	if target.IsZero() {
+		if len(children) > 0 {
+			return jobs.Job{}, &ValidationError{Field: "target",
+				Message: fmt.Sprintf("category has %d sub categories, choose where to move them", len(children))}
+		}
+		refs, err := a.Store.CountCategoryRefs(ctx, id)
+		if err != nil {
+			return jobs.Job{}, err
+		}
+		if refs > 0 {
+			return jobs.Job{}, &ValidationError{Field: "target",
+				Message: fmt.Sprintf("category is used by %d feeds and items, choose where to move them", refs)}
+		}
+		return jobs.Job{}, a.Store.DeleteCategory(ctx, id)
+	}
+	if target == id {
+		return jobs.Job{}, &ValidationError{Field: "target", Message: "cannot merge a category into itself"}
+	}
+	to, err := a.Store.GetCategoryByID(ctx, target)
+	if errors.Is(err, store.ErrNotFound) {
+		return jobs.Job{}, &ValidationError{Field: "target", Message: "does not exist"}
+	}
+	if err != nil {
+		return jobs.Job{}, err
+	}
+	return a.reassignCategory(ctx, id, to, "merging category "+category.Name+" into "+to.Name,
+		func(ctx context.Context) error {
+			for _, child := range children {
+				child.ParentID = to.ID
+				if err := a.Store.UpdateSubCategory(ctx, child); err != nil {
+					return err
+				}
+				err := batches(func(int) {}, func() (int, error) {
+					return a.Store.ReassignSubCategory(ctx, child.ID, child, batchSize)
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return a.Store.DeleteCategory(ctx, id)
+		})
+}
+
+// children returns the subcategories of a category.
+func (a *Service) children(ctx context.Context, id primitive.ObjectID) ([]rss.SubCategory, error) {
+	subCategories, err := a.Store.GetSubCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var children []rss.SubCategory
+	for _, subCategory := range subCategories {
+		if subCategory.ParentID == id {
+			children = append(children, subCategory)
+		}
+	}
+	return children, nil
+}
+
+// DeleteSubCategory removes a subcategory like DeleteCategory.
+func (a *Service) DeleteSubCategory(ctx context.Context, id, target primitive.ObjectID) (jobs.Job, error) {
+	subCategory, err := a.Store.GetSubCategoryByID(ctx, id)
+	if err != nil {
+		return jobs.Job{}, err
+	}
+	if target.IsZero() {
+		refs, err := a.Store.CountSubCategoryRefs(ctx, id)
+		if err != nil {
+			return jobs.Job{}, err
+		}
+		if refs > 0 {
+			return jobs.Job{}, &ValidationError{Field: "target",
+				Message: fmt.Sprintf("subcategory is used by %d feeds and items, choose where to move them", refs)}
+		}
+		return jobs.Job{}, a.Store.DeleteSubCategory(ctx, id)
+	}
+	if target == id {
+		return jobs.Job{}, &ValidationError{Field: "target", Message: "cannot merge a subcategory into itself"}
+	}
+	to, err := a.Store.GetSubCategoryByID(ctx, target)
+	if errors.Is(err, store.ErrNotFound) {
+		return jobs.Job{}, &ValidationError{Field: "target", Message: "does not exist"}
+	}
+	if err != nil {
+		return jobs.Job{}, err
+	}
+	if to.ParentID != subCategory.ParentID {
+		return jobs.Job{}, &ValidationError{Field: "target", Message: "must be in the same category"}
+	}
+	return a.reassignSubCategory(ctx, id, to, "merging subcategory "+subCategory.SubCategory+" into "+to.SubCategory,
+		func(ctx context.Context) error {
+			return a.Store.DeleteSubCategory(ctx, id)
+		})
+}
+
+// reassignCategory starts a job moving the feeds and items in category from
+// to category to, running then when all are moved.
+func (a *Service) reassignCategory(ctx context.Context, from primitive.ObjectID, to rss.Category, name string, then func(context.Context) error) (jobs.Job, error) {
+	total, err := a.Store.CountCategoryRefs(ctx, from)
+	if err != nil {
+		return jobs.Job{}, err
+	}
+	return a.Jobs.Start(name, total, func(ctx context.Context, progress func(int)) error {
+		err := batches(progress, func() (int, error) {
+			return a.Store.ReassignCategory(ctx, from, to, batchSize)
+		})
+		if err != nil || then == nil {
+			return err
+		}
+		return then(ctx)
+	}), nil
+}
+
+func (a *Service) reassignSubCategory(ctx context.Context, from primitive.ObjectID, to rss.SubCategory, name string, then func(context.Context) error) (jobs.Job, error) {
+	total, err := a.Store.CountSubCategoryRefs(ctx, from)
+	if err != nil {
+		return jobs.Job{}, err
+	}
+	return a.Jobs.Start(name, total, func(ctx context.Context, progress func(int)) error {
+		err := batches(progress, func() (int, error) {
+			return a.Store.ReassignSubCategory(ctx, from, to, batchSize)
+		})
+		if err != nil || then == nil {
+			return err
+		}
+		return then(ctx)
+	}), nil
+}
+
+// batches runs batch until it changes nothing.
+func batches(progress func(int), batch func() (int, error)) error {
+	for {
+		n, err := batch()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+		progress(n)
+	}
+}
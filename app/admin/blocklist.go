@@ -0,0 +1,92 @@
+package admin
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/blocklist"
+	"github.com/jelinden/rssfetcher/app/domain"
+	"github.com/jelinden/rssfetcher/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AddBlockRule validates and stores a global block rule, then hides the
+// stored items it matches.
+func (a *Service) AddBlockRule(ctx context.Context, rule domain.BlockRule) (domain.BlockRule, error) {
+	rule.Reason = strings.TrimSpace(rule.Reason)
+	if !slices.Contains(domain.BlockKinds, rule.Kind) {
+		return rule, &ValidationError{Field: "kind", Message: "must be one of " + strings.Join(domain.BlockKinds, ", ")}
+	}
+	normalized, err := blocklist.Normalize(rule)
+	if err != nil {
+		return rule, &ValidationError{Field: "value", Message: err.Error()}
+	}
+	rule = normalized
+	if rule.Reason == "" {
+		return rule, &ValidationError{Field: "reason", Message: "is required"}
+	}
+	rule.ID = primitive.NewObjectID()
+	rule.Created = time.Now()
+	if err := a.Store.SaveBlockRule(ctx, rule); err != nil {
+		return rule, err
+	}
+	q := store.ItemQuery{}
+	if rule.Kind == domain.BlockKeyword {
+		q.Text = rule.Value
+	}
+	hidden, err := a.hideBlocked(ctx, q, blocklist.New([]domain.BlockRule{rule}))
+	rule.HiddenItems = hidden
+	if err != nil {
+		return rule, err
+	}
+	return rule, a.Store.SaveBlockRule(ctx, rule)
+}
+
+// hideBlocked hides the visible stored items matching q and the list, a
+// batch at a time, and returns how many.
+func (a *Service) hideBlocked(ctx context.Context, q store.ItemQuery, list *blocklist.List) (int, error) {
+	q.Limit = batchSize
+	hidden := 0
+	for {
+		items, err := a.Store.FindItems(ctx, q)
+		if err != nil {
+			return hidden, err
+		}
+		ids := make(map[domain.BlockRule][]primitive.ObjectID)
+		for _, item := range items {
+			if rule, ok := list.Match(item); ok {
+				ids[rule] = append(ids[rule], item.ID)
+			}
+		}
+		for rule, ruleIDs := range ids {
+			if err := a.Store.HideItems(ctx, ruleIDs, rule); err != nil {
+				return hidden, err
+			}
+			hidden += len(ruleIDs)
+		}
+		if len(items) < batchSize {
+			return hidden, nil
+		}
+		q.Cursor = store.CursorOf(items[len(items)-1])
+	}
+}
+
+// DeleteBlockRule removes a global block rule and shows the items it hid
+// again, unless another rule matches them, returning how many.
+func (a *Service) DeleteBlockRule(ctx context.Context, id primitive.ObjectID) (int, error) {
+	if err := a.Store.DeleteBlockRule(ctx, id); err != nil {
+		return 0, err
+	}
+	shown, err := a.Store.UnhideItems(ctx, id)
+	if err != nil || shown == 0 {
+		return shown, err
+	}
+	rules, err := a.Store.GetBlockRules(ctx)
+	if err != nil || len(rules) == 0 {
+		return shown, err
+	}
+	hidden, err := a.hideBlocked(ctx, store.ItemQuery{}, blocklist.New(rules))
+	return shown - hidden, err
+}
@@ -0,0 +1,139 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jelinden/rssfetcher/app/domain"
+	"github.com/jelinden/rssfetcher/app/process"
+	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/rules"
+	"github.com/jelinden/rssfetcher/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// dryRunItems is how many of the latest items of a feed rules are tried on.
+const dryRunItems = 100
+
+// SaveFeed validates a feed edited in the admin and stores it, inserting it
+// when no feed has its ID. The category and subcategory are looked up by ID,
+// or by name when the ID is zero, and the stored ones used. Fields not
+// edited in the admin are kept from the stored feed.
+func (a *Service) SaveFeed(ctx context.Context, feed domain.Feed) (domain.Feed, error) {
+	feed.Name = strings.TrimSpace(feed.Name)
+	feed.URL = strings.TrimSpace(feed.URL)
+	feed.SiteURL = strings.TrimSpace(feed.SiteURL)
+	if feed.Name == "" {
+		return feed, &ValidationError{Field: "feedTitle", Message: "is required"}
+	}
+	if !isHTTPURL(feed.URL) {
+		return feed, &ValidationError{Field: "url", Message: "must be an http or https URL"}
+	}
+	if feed.SiteURL != "" && !isHTTPURL(feed.SiteURL) {
+		return feed, &ValidationError{Field: "siteUrl", Message: "must be an http or https URL"}
+	}
+	if feed.MinTitleLength < 0 {
+		return feed, &ValidationError{Field: "minTitleLength", Message: "must not be negative"}
+	}
+	if _, err := process.ForFeed(feed); err != nil {
+		var invalidRule *rules.Error
+		if errors.As(err, &invalidRule) {
+			return feed, &ValidationError{Field: invalidRule.List, Message: "rule " + strconv.Itoa(invalidRule.Number) + ": " + invalidRule.Err.Error()}
+		}
+		var invalidProcessor *process.Error
+		if errors.As(err, &invalidProcessor) {
+			return feed, &ValidationError{Field: "processors", Message: invalidProcessor.Error()}
+		}
+		return feed, err
+	}
+	category, err := a.findCategory(ctx, feed.Category)
+	if err != nil {
+		return feed, err
+	}
+	feed.Category = category
+	if feed.SubCategory != nil && (!feed.SubCategory.ID.IsZero() || feed.SubCategory.SubCategory != "") {
+		subCategory, err := a.findSubCategory(ctx, *feed.SubCategory)
+		if err != nil {
+			return feed, err
+		}
+		feed.SubCategory = &subCategory
+	} else {
+		feed.SubCategory = nil
+	}
+	if err := CheckFeedCategories(feed.Category, feed.SubCategory); err != nil {
+		return feed, err
+	}
+	if feed.ID.IsZero() {
+		feed.ID = primitive.NewObjectID()
+	}
+	stored, err := a.Store.GetFeed(ctx, feed.ID.Hex())
+	switch {
+	case err == nil:
+		feed.Removed = stored.Removed
+		feed.Publisher = stored.Publisher
+	case !errors.Is(err, store.ErrNotFound):
+		return feed, err
+	}
+	return feed, a.Store.SaveFeed(ctx, feed)
+}
+
+// DryRunRules returns the recent items of a feed that each of its rules
+// would drop.
+func (a *Service) DryRunRules(ctx context.Context, feed domain.Feed) ([]domain.RuleResult, error) {
+	compiled, err := rules.Compile(feed)
+	if err != nil {
+		return nil, err
+	}
+	items, err := a.Store.FindItems(ctx, store.ItemQuery{FeedID: feed.ID, Limit: dryRunItems})
+	if err != nil {
+		return nil, err
+	}
+	return compiled.DryRun(items), nil
+}
+
+// RemoveFeed marks a feed removed so it is no longer fetched.
+func (a *Service) RemoveFeed(ctx context.Context, id string) error {
+	feed, err := a.Store.GetFeed(ctx, id)
+	if err != nil {
+		return err
+	}
+	feed.Removed = true
+	return a.Store.SaveFeed(ctx, *feed)
+}
+
+func (a *Service) findCategory(ctx context.Context, category rss.Category) (rss.Category, error) {
+	var err error
+	switch {
+	case !category.ID.IsZero():
+		category, err = a.Store.GetCategoryByID(ctx, category.ID)
+	case category.Name != "":
+		category, err = a.Store.GetCategory(ctx, category.Name)
+	default:
+		return category, &ValidationError{Field: "category", Message: "is required"}
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		return category, &ValidationError{Field: "category", Message: "does not exist"}
+	}
+	return category, err
+}
+
+func (a *Service) findSubCategory(ctx context.Context, subCategory rss.SubCategory) (rss.SubCategory, error) {
+	var err error
+	if !subCategory.ID.IsZero() {
+		subCategory, err = a.Store.GetSubCategoryByID(ctx, subCategory.ID)
+	} else {
+		subCategory, err = a.Store.GetSubCategory(ctx, subCategory.SubCategory)
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		return subCategory, &ValidationError{Field: "subCategory", Message: "does not exist"}
+	}
+	return subCategory, err
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
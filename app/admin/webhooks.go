@@ -0,0 +1,77 @@
+package admin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/domain"
+	"github.com/jelinden/rssfetcher/app/store"
+	"github.com/jelinden/rssfetcher/app/webhook"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SaveWebhook validates a webhook edited in the admin and stores it,
+// inserting it when no webhook has its ID. A webhook saved without a secret
+// gets a random one.
+func (a *Service) SaveWebhook(ctx context.Context, hook domain.Webhook) (domain.Webhook, error) {
+	hook.URL = strings.TrimSpace(hook.URL)
+	hook.Secret = strings.TrimSpace(hook.Secret)
+	hook.Filter.Category = strings.TrimSpace(hook.Filter.Category)
+	hook.Filter.Language = strings.TrimSpace(hook.Filter.Language)
+	if !isHTTPURL(hook.URL) {
+		return hook, &ValidationError{Field: "url", Message: "must be an http or https URL"}
+	}
+	if _, err := webhook.Compile(hook.Filter.Pattern); err != nil {
+		return hook, &ValidationError{Field: "pattern", Message: "is not a valid regular expression"}
+	}
+	if !hook.Filter.FeedID.IsZero() {
+		if _, err := a.Store.GetFeed(ctx, hook.Filter.FeedID.Hex()); errors.Is(err, store.ErrNotFound) {
+			return hook, &ValidationError{Field: "feedId", Message: "is not a known feed"}
+		} else if err != nil {
+			return hook, err
+		}
+	}
+	if hook.ID.IsZero() {
+		hook.ID = primitive.NewObjectID()
+	}
+	stored, err := a.Store.GetWebhook(ctx, hook.ID)
+	switch {
+	case err == nil:
+		hook.Created = stored.Created
+	case errors.Is(err, store.ErrNotFound):
+		hook.Created = time.Now()
+	default:
+		return hook, err
+	}
+	if hook.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return hook, err
+		}
+		hook.Secret = hex.EncodeToString(secret)
+	}
+	return hook, a.Store.SaveWebhook(ctx, hook)
+}
+
+func (a *Service) DeleteWebhook(ctx context.Context, id primitive.ObjectID) error {
+	return a.Store.DeleteWebhook(ctx, id)
+}
+
+// RetryDelivery starts a failed webhook delivery again.
+func (a *Service) RetryDelivery(ctx context.Context, id primitive.ObjectID) error {
+	if a.Webhooks == nil {
+		return errors.New("webhooks are disabled")
+	}
+	delivery, err := a.Store.GetDelivery(ctx, id)
+	if err != nil {
+		return err
+	}
+	if delivery.Status != domain.DeliveryFailed {
+		return &ValidationError{Field: "status", Message: "only failed deliveries can be retried"}
+	}
+	return a.Webhooks.Retry(ctx, id)
+}
@@ -0,0 +1,97 @@
+package blocklist
+
+import (
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/jelinden/rssfetcher/app/domain"
+	"github.com/jelinden/rssfetcher/app/rss"
+)
+
+// List is the global blocklist ready for matching items.
+type List struct {
+	rules []domain.BlockRule
+}
+
+// New makes a list of rules whose values are Normalized.
+func New(rules []domain.BlockRule) *List {
+	return &List{rules: rules}
+}
+
+func (l *List) Empty() bool {
+	return len(l.rules) == 0
+}
+
+// Match returns the first rule matching item.
+func (l *List) Match(item rss.Item) (domain.BlockRule, bool) {
+	for _, rule := range l.rules {
+		if Matches(rule, item) {
+			return rule, true
+		}
+	}
+	return domain.BlockRule{}, false
+}
+
+// Matches reports whether the rule blocks item. Domains match the item link
+// and original link hosts and their subdomains, URLs the links compared as
+// canonical URLs, and keywords the title and description ignoring case.
+func Matches(rule domain.BlockRule, item rss.Item) bool {
+	links := []string{item.Link, item.OriginalLink}
+	switch rule.Kind {
+	case domain.BlockDomain:
+		return slices.ContainsFunc(links, func(link string) bool {
+			host := host(link)
+			return host != "" && (host == rule.Value || strings.HasSuffix(host, "."+rule.Value))
+		})
+	case domain.BlockURL:
+		return slices.ContainsFunc(links, func(link string) bool {
+			return link != "" && rss.CanonicalURL(link) == rule.Value
+		})
+	case domain.BlockKeyword:
+		return strings.Contains(strings.ToLower(item.Title), rule.Value) ||
+			strings.Contains(strings.ToLower(item.Content), rule.Value)
+	}
+	return false
+}
+
+// Normalize checks the value of a rule and brings it to the form Matches
+// compares: a lower case host name for domains, a canonical URL for URLs and
+// lower case for keywords.
+func Normalize(rule domain.BlockRule) (domain.BlockRule, error) {
+	value := strings.TrimSpace(rule.Value)
+	switch rule.Kind {
+	case domain.BlockDomain:
+		if !strings.Contains(value, "://") {
+			value = "http://" + value
+		}
+		value = host(value)
+		if !strings.Contains(value, ".") {
+			return rule, errors.New("must be a domain name such as example.com")
+		}
+	case domain.BlockURL:
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return rule, errors.New("must be an http or https URL")
+		}
+		value = rss.CanonicalURL(value)
+	case domain.BlockKeyword:
+		value = strings.ToLower(value)
+		if value == "" {
+			return rule, errors.New("is required")
+		}
+	default:
+		return rule, errors.New("must be one of " + strings.Join(domain.BlockKinds, ", "))
+	}
+	rule.Value = value
+	return rule, nil
+}
+
+func host(link string) string {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+}
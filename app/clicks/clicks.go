@@ -0,0 +1,87 @@
+package clicks
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// botAgents are user agent fragments of crawlers, link previewers and HTTP
+// libraries, matched in lower case.
+var botAgents = []string{
+	"bot", "crawl", "spider", "slurp", "preview", "facebookexternalhit",
+	"embedly", "quora link", "whatsapp", "bitlybot", "headless", "lighthouse",
+	"curl", "wget", "python", "go-http-client", "java/", "okhttp", "libwww",
+}
+
+// IsBot reports whether a user agent is a crawler or a tool rather than a
+// reader. Requests without a user agent count as bots.
+func IsBot(userAgent string) bool {
+	ua := strings.ToLower(strings.TrimSpace(userAgent))
+	if ua == "" {
+		return true
+	}
+	for _, fragment := range botAgents {
+		if strings.Contains(ua, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+type visit struct {
+	ip     string
+	itemID primitive.ObjectID
+}
+
+// Tracker counts clicks on news items, once per address and item within
+// Window.
+type Tracker struct {
+	Store  store.Store
+	Window time.Duration
+
+	mu    sync.Mutex
+	seen  map[visit]time.Time
+	swept time.Time
+}
+
+func New(s store.Store, window time.Duration) *Tracker {
+	return &Tracker{Store: s, Window: window, seen: make(map[visit]time.Time)}
+}
+
+// Click records a click from ip unless the user agent is a bot or the same
+// address clicked the item within the window. It reports whether the click
+// was counted.
+func (t *Tracker) Click(ctx context.Context, itemID primitive.ObjectID, ip, userAgent string) (bool, error) {
+	if IsBot(userAgent) || t.repeated(visit{ip, itemID}, time.Now()) {
+		return false, nil
+	}
+	if err := t.Store.AddClick(ctx, itemID, time.Now()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// repeated reports whether v was seen within the window and remembers it
+// otherwise. Expired visits are swept once per window.
+func (t *Tracker) repeated(v visit, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if now.Sub(t.swept) > t.Window {
+		for old, at := range t.seen {
+			if now.Sub(at) > t.Window {
+				delete(t.seen, old)
+			}
+		}
+		t.swept = now
+	}
+	if at, ok := t.seen[v]; ok && now.Sub(at) <= t.Window {
+		return true
+	}
+	t.seen[v] = now
+	return false
+}
@@ -0,0 +1,107 @@
+package cluster
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const minWords = 3
+
+// Clusterer groups items of the same language published within Window whose
+// title fingerprints differ in at most MaxDistance of 64 bits.
+type Clusterer struct {
+	Store       store.Store
+	Window      time.Duration
+	MaxDistance int
+}
+
+func New(s store.Store, window time.Duration, maxDistance int) *Clusterer {
+	return &Clusterer{Store: s, Window: window, MaxDistance: maxDistance}
+}
+
+type cluster struct {
+	leader  uint64
+	short   bool
+	members []rss.Item
+}
+
+// Run reclusters the items in the window and stores the changed
+// assignments.
+func (c *Clusterer) Run(ctx context.Context) error {
+	items, err := c.Store.FindItems(ctx, store.ItemQuery{After: time.Now().Add(-c.Window), Oldest: true})
+	if err != nil {
+		return err
+	}
+	byLanguage := make(map[string][]rss.Item)
+	for _, item := range items {
+		byLanguage[item.Language] = append(byLanguage[item.Language], item)
+	}
+	var updates []store.ClusterUpdate
+	for _, items := range byLanguage {
+		for _, cl := range c.group(items) {
+			updates = append(updates, assign(cl.members)...)
+		}
+	}
+	if len(updates) > 0 {
+		log.Println("clustering updated", len(updates), "items")
+	}
+	return c.Store.SetClusters(ctx, updates)
+}
+
+// group compares each item, oldest first, to the first item of every
+// cluster so that long chains of slightly different titles do not merge
+// unrelated stories.
+func (c *Clusterer) group(items []rss.Item) []*cluster {
+	var clusters []*cluster
+	for _, item := range items {
+		hash, words := simHash(item.Title)
+		var found *cluster
+		for _, cl := range clusters {
+			if words >= minWords && !cl.short && distance(cl.leader, hash) <= c.MaxDistance {
+				found = cl
+				break
+			}
+		}
+		if found == nil {
+			found = &cluster{leader: hash, short: words < minWords}
+			clusters = append(clusters, found)
+		}
+		found.members = append(found.members, item)
+	}
+	return clusters
+}
+
+// assign returns the updates for the members whose cluster or
+// representative changed. A cluster keeps the ID it already has, items
+// that are alone are not in a cluster.
+func assign(members []rss.Item) []store.ClusterUpdate {
+	var clusterID primitive.ObjectID
+	rep := 0
+	if len(members) > 1 {
+		clusterID = members[0].ID
+		for _, item := range members {
+			if !item.ClusterID.IsZero() {
+				clusterID = item.ClusterID
+				break
+			}
+		}
+		for i, item := range members {
+			if item.Clicks > members[rep].Clicks {
+				rep = i
+			}
+		}
+	}
+	var updates []store.ClusterUpdate
+	for i, item := range members {
+		representative := !clusterID.IsZero() && i == rep
+		if item.ClusterID != clusterID || item.Representative != representative {
+			updates = append(updates, store.ClusterUpdate{ItemID: item.ID, ClusterID: clusterID, Representative: representative})
+		}
+	}
+	return updates
+}
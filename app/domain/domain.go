@@ -1,29 +1,190 @@
 package domain
 
 import (
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/jobs"
 	"github.com/jelinden/rssfetcher/app/rss"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type Feed struct {
-	ID          primitive.ObjectID `json:"id" bson:"_id"`
-	Name        string             `json:"feedTitle" bson:"feedTitle,omitempty"`
-	URL         string             `json:"url" bson:"url,omitempty"`
-	SiteURL     string             `json:"siteUrl" bson:"siteUrl,omitempty"`
-	Category    rss.Category       `json:"category" bson:"category,omitempty"`
-	SubCategory *rss.SubCategory   `json:"subCategory" bson:"subCategory,omitempty"`
-	Language    string             `json:"language" bson:"language,omitempty"`
-	Removed     bool               `json:"removed" bson:"removed,omitempty"`
+	ID           primitive.ObjectID `json:"id" bson:"_id"`
+	Name         string             `json:"feedTitle" bson:"feedTitle,omitempty"`
+	URL          string             `json:"url" bson:"url,omitempty"`
+	SiteURL      string             `json:"siteUrl" bson:"siteUrl,omitempty"`
+	Category     rss.Category       `json:"category" bson:"category,omitempty"`
+	SubCategory  *rss.SubCategory   `json:"subCategory" bson:"subCategory,omitempty"`
+	Language     string             `json:"language" bson:"language,omitempty"`
+	Removed      bool               `json:"removed" bson:"removed,omitempty"`
+	OGImage      bool               `json:"ogImage" bson:"ogImage"`
+	ResolveLinks bool               `json:"resolveLinks" bson:"resolveLinks"`
+	Publisher    *Publisher         `json:"publisher" bson:"publisher,omitempty"`
+	// Fetched items matching none of the Include rules, when there are any,
+	// or any of the Exclude rules are not stored, nor are items with titles
+	// shorter than MinTitleLength characters.
+	Include        []FilterRule `json:"include" bson:"include,omitempty"`
+	Exclude        []FilterRule `json:"exclude" bson:"exclude,omitempty"`
+	MinTitleLength int          `json:"minTitleLength" bson:"minTitleLength,omitempty"`
+	// Processors are run in order on each fetched item after the standard
+	// cleanup.
+	Processors []ProcessorConfig `json:"processors" bson:"processors,omitempty"`
+}
+
+// ProcessorConfig names an item processor and its settings.
+type ProcessorConfig struct {
+	Name    string `json:"name" bson:"name"`
+	Pattern string `json:"pattern,omitempty" bson:"pattern,omitempty"`
+	Replace string `json:"replace,omitempty" bson:"replace,omitempty"`
+}
+
+// FilterRule matches items whose Field, one of title, content, link or
+// tags, matches the regular expression Pattern.
+type FilterRule struct {
+	Field   string `json:"field" bson:"field"`
+	Pattern string `json:"pattern" bson:"pattern"`
+}
+
+// Publisher is the channel metadata as the feed itself describes it, kept
+// apart from the Name and SiteURL set in the admin.
+type Publisher struct {
+	Title       string     `json:"title" bson:"title"`
+	Description string     `json:"description" bson:"description"`
+	Link        string     `json:"link" bson:"link"`
+	Image       *rss.Image `json:"image" bson:"image,omitempty"`
+	Updated     time.Time  `json:"updated" bson:"updated"`
 }
 
 type ViewPage struct {
 	Feeds         []Feed
 	Categories    []rss.Category
 	SubCategories []rss.SubCategory
+	Jobs          []jobs.Job
 }
 
 type EditPage struct {
 	Feed          Feed
 	Categories    []rss.Category
 	SubCategories []rss.SubCategory
+	// Include and Exclude are the feed's rules, one per line.
+	Include string
+	Exclude string
+	DryRun  []RuleResult
+	// Processors are the feed's processors, one per line.
+	Processors string
+	Error      string
+}
+
+// RuleResult lists the items a feed rule would drop.
+type RuleResult struct {
+	Rule  string
+	Items []rss.Item
+}
+
+type CategoryPage struct {
+	Category rss.Category
+	// Targets are the other categories a deleted one can be merged into.
+	Targets []rss.Category
+	Error   string
+}
+
+type SubCategoryPage struct {
+	SubCategory rss.SubCategory
+	Categories  []rss.Category
+	// Targets are the other subcategories of the same category.
+	Targets []rss.SubCategory
+	Error   string
+}
+
+// Webhook posts the new news items matching Filter to URL, signed with
+// Secret.
+type Webhook struct {
+	ID      primitive.ObjectID `json:"id" bson:"_id"`
+	URL     string             `json:"url" bson:"url"`
+	Filter  WebhookFilter      `json:"filter" bson:"filter"`
+	Secret  string             `json:"secret" bson:"secret"`
+	Created time.Time          `json:"created" bson:"created"`
+}
+
+// WebhookFilter fields that are empty match every item.
+type WebhookFilter struct {
+	FeedID primitive.ObjectID `json:"feedId" bson:"feedId,omitempty"`
+	// Category is matched on the category enName or name.
+	Category string `json:"category" bson:"category,omitempty"`
+	Language string `json:"language" bson:"language,omitempty"`
+	// Pattern is a regular expression matched against the item title and
+	// description.
+	Pattern string `json:"pattern" bson:"pattern,omitempty"`
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	// DeliveryFailed deliveries ran out of attempts and wait in the dead
+	// letter list for a manual retry.
+	DeliveryFailed = "failed"
+)
+
+// Delivery is a webhook call for one item and the outcome of its latest
+// attempt.
+type Delivery struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	WebhookID primitive.ObjectID `json:"webhookId" bson:"webhookId"`
+	ItemID    primitive.ObjectID `json:"itemId" bson:"itemId"`
+	Title     string             `json:"title" bson:"title"`
+	Payload   string             `json:"payload" bson:"payload"`
+	Status    string             `json:"status" bson:"status"`
+	Attempts  int                `json:"attempts" bson:"attempts"`
+	// StatusCode is the HTTP status of the latest attempt, Error why it
+	// failed.
+	StatusCode int       `json:"statusCode" bson:"statusCode"`
+	Error      string    `json:"error" bson:"error"`
+	Created    time.Time `json:"created" bson:"created"`
+	Updated    time.Time `json:"updated" bson:"updated"`
+}
+
+type WebhooksPage struct {
+	Webhooks    []Webhook
+	Feeds       []Feed
+	Categories  []rss.Category
+	DeadLetters []Delivery
+	Error       string
+}
+
+type WebhookPage struct {
+	Webhook    Webhook
+	Feeds      []Feed
+	Categories []rss.Category
+	Deliveries []Delivery
+	Error      string
+}
+
+const (
+	BlockDomain  = "domain"
+	BlockURL     = "url"
+	BlockKeyword = "keyword"
+)
+
+// BlockKinds are what a block rule can match: the link's domain and its
+// subdomains, a link, or a word or phrase in the title or description.
+var BlockKinds = []string{BlockDomain, BlockURL, BlockKeyword}
+
+// BlockRule hides the items of every feed that match it.
+type BlockRule struct {
+	ID     primitive.ObjectID `json:"id" bson:"_id"`
+	Kind   string             `json:"kind" bson:"kind"`
+	Value  string             `json:"value" bson:"value"`
+	Reason string             `json:"reason" bson:"reason"`
+	// HiddenItems is how many stored items were hidden when the rule was
+	// added.
+	HiddenItems int       `json:"hiddenItems" bson:"hiddenItems"`
+	Created     time.Time `json:"created" bson:"created"`
+}
+
+type BlocklistPage struct {
+	Rules []BlockRule
+	// Rule is the rule being added, kept when it is invalid.
+	Rule  BlockRule
+	Kinds []string
+	Error string
 }
@@ -0,0 +1,209 @@
+package fetcher
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/blocklist"
+	"github.com/jelinden/rssfetcher/app/cluster"
+	"github.com/jelinden/rssfetcher/app/domain"
+	"github.com/jelinden/rssfetcher/app/process"
+	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/store"
+	"github.com/jelinden/rssfetcher/app/stream"
+	"github.com/jelinden/rssfetcher/app/webhook"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const itemsPerFeed = 5
+
+type Fetcher struct {
+	Store store.Store
+	// Cluster, when set, groups the stored items into stories after each tick.
+	Cluster *cluster.Clusterer
+	// Stream, when set, gets the inserted and changed items.
+	Stream *stream.Hub
+	// Webhooks, when set, delivers the inserted items to webhooks.
+	Webhooks *webhook.Dispatcher
+}
+
+func New(s store.Store) *Fetcher {
+	return &Fetcher{Store: s}
+}
+
+func (f *Fetcher) Run(d time.Duration) {
+	for range time.Tick(d) {
+		feedList, err := f.Store.GetFeeds(context.Background(), true)
+		if err != nil {
+			log.Println("getting feeds failed", err)
+			continue
+		}
+		f.GetNews(feedList)
+		if f.Cluster != nil {
+			if err := f.Cluster.Run(context.Background()); err != nil {
+				log.Println("clustering failed", err)
+			}
+		}
+	}
+}
+
+type feedStruct struct {
+	RSSFeed domain.Feed
+	Item    rss.Feed
+}
+
+func (f *Fetcher) GetNews(feeds []domain.Feed) {
+	if len(feeds) > 0 {
+		log.Println("getting news")
+		c := make(chan *feedStruct)
+		go getNewsFeeds(feeds, c)
+		counter := 0
+		t := time.Now()
+		blockRules := f.blockRules()
+		changes := []store.ItemChange{}
+		for i := range c {
+			counter++
+			if i != nil {
+				f.savePublisher(i.RSSFeed, i.Item)
+				changes = append(changes, f.saveNewsItems(i.Item, i.RSSFeed, blockRules)...)
+				log.Println("feed", i.RSSFeed.Name, i.RSSFeed.Category.Name, time.Since(t).Seconds(), "s")
+			}
+			if counter == len(feeds) {
+				close(c)
+			}
+		}
+		log.Println("got all and closed the channel")
+		f.publish(f.hideAdded(changes, blockRules))
+	}
+}
+
+func getNewsFeeds(feeds []domain.Feed, c chan *feedStruct) {
+	for i := range feeds {
+		go getNewsFeed(feeds, c, i)
+	}
+}
+
+func getNewsFeed(feeds []domain.Feed, c chan *feedStruct, i int) {
+	item, err := rss.Fetch(feeds[i].URL)
+	if err != nil {
+		log.Println("err", feeds[i].URL, err)
+		c <- nil
+	} else {
+		if feeds[i].ResolveLinks {
+			rss.ResolveLinks(item, itemsPerFeed)
+		}
+		if feeds[i].OGImage {
+			rss.EnrichImages(item, itemsPerFeed)
+		}
+		items := feedStruct{RSSFeed: feeds[i], Item: *item}
+		c <- &items
+	}
+}
+
+func (f *Fetcher) savePublisher(feed domain.Feed, channel rss.Feed) {
+	image := channel.Image
+	if image != nil && image.Url == "" {
+		image = nil
+	}
+	publisher := domain.Publisher{
+		Title:       strings.TrimSpace(channel.Title),
+		Description: strings.TrimSpace(channel.Description),
+		Link:        strings.TrimSpace(channel.Link),
+		Image:       image,
+		Updated:     time.Now()}
+	if err := f.Store.SavePublisher(context.Background(), feed.ID, publisher); err != nil {
+		log.Println("saving publisher failed", err)
+	}
+}
+
+func (f *Fetcher) blockRules() []domain.BlockRule {
+	rules, err := f.Store.GetBlockRules(context.Background())
+	if err != nil {
+		log.Println("getting block rules failed", err)
+	}
+	return rules
+}
+
+// saveNewsItems stores the items of a feed that its processors and the
+// block rules keep and returns the changes.
+func (f *Fetcher) saveNewsItems(items rss.Feed, feed domain.Feed, blockRules []domain.BlockRule) []store.ItemChange {
+	chain, err := process.ForFeed(feed)
+	if err != nil {
+		log.Println("skipping feed with invalid processors or rules", feed.Name, err)
+		return nil
+	}
+	if len(blockRules) > 0 {
+		chain = append(chain, process.Blocklist{List: blocklist.New(blockRules)})
+	}
+	news := []rss.Item{}
+	dropped := 0
+	for k, item := range items.Items {
+		if k >= itemsPerFeed {
+			break
+		}
+		keep, err := chain.Process(context.Background(), feed, item)
+		if err != nil {
+			log.Println("processing item failed", feed.Name, err)
+		}
+		if !keep {
+			dropped++
+			continue
+		}
+		news = append(news, *item)
+	}
+	if dropped > 0 {
+		log.Println("processors dropped", dropped, "items of", feed.Name)
+	}
+	changes, err := f.Store.SaveItems(context.Background(), news)
+	if err != nil {
+		log.Println("saving news items failed", err)
+		return nil
+	}
+	return changes
+}
+
+func (f *Fetcher) publish(changes []store.ItemChange) {
+	if f.Stream != nil {
+		f.Stream.Publish(changes)
+	}
+	if f.Webhooks != nil {
+		f.Webhooks.Publish(context.Background(), changes)
+	}
+}
+
+// hideAdded hides the items saved in a tick that match block rules added
+// since known was read, as the admin may have hidden the stored items of a
+// new rule before these were saved, and returns the changes left visible.
+func (f *Fetcher) hideAdded(changes []store.ItemChange, known []domain.BlockRule) []store.ItemChange {
+	if len(changes) == 0 {
+		return changes
+	}
+	var added []domain.BlockRule
+	for _, rule := range f.blockRules() {
+		if !slices.ContainsFunc(known, func(k domain.BlockRule) bool { return k.ID == rule.ID }) {
+			added = append(added, rule)
+		}
+	}
+	if len(added) == 0 {
+		return changes
+	}
+	list := blocklist.New(added)
+	ids := make(map[domain.BlockRule][]primitive.ObjectID)
+	visible := changes[:0]
+	for _, change := range changes {
+		if rule, ok := list.Match(change.Item); ok {
+			ids[rule] = append(ids[rule], change.Item.ID)
+			continue
+		}
+		visible = append(visible, change)
+	}
+	for rule, ruleIDs := range ids {
+		if err := f.Store.HideItems(context.Background(), ruleIDs, rule); err != nil {
+			log.Println("hiding blocked items failed", err)
+		}
+	}
+	return visible
+}
@@ -0,0 +1,131 @@
+package fetcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/domain"
+	"github.com/jelinden/rssfetcher/app/memory"
+	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSaveNewsItems(t *testing.T) {
+	s := memory.New()
+	f := New(s)
+	feed := domain.Feed{
+		ID:         primitive.NewObjectID(),
+		Name:       "Example",
+		Language:   "fi",
+		Category:   rss.Category{ID: primitive.NewObjectID(), Name: "Urheilu", EnName: "sports"},
+		Exclude:    []domain.FilterRule{{Field: "title", Pattern: "(?i)horoscope"}},
+		Processors: []domain.ProcessorConfig{{Name: "titleRewrite", Pattern: `\s*\|\s*Example$`}},
+	}
+	now := time.Now()
+	channel := rss.Feed{Items: []*rss.Item{
+		{Title: " Goal! | Example ", Link: "https://example.com/1", GUID: "https://example.com/1", Date: now.Add(time.Hour)},
+		{Title: "Daily horoscope", Link: "https://example.com/2", GUID: "https://example.com/2", Date: now},
+		{Title: "No link", GUID: "https://example.com/3", Date: now},
+		{Title: "Short guid", Link: "https://example.com/4", GUID: "x", Date: now},
+	}}
+	changes := f.saveNewsItems(channel, feed, nil)
+
+	items, err := s.FindItems(context.Background(), store.ItemQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Fatal("want one item, got", items)
+	}
+	item := items[0]
+	if item.Title != "Goal!" || item.GUID != "example.com/1" || item.FeedID != feed.ID ||
+		item.Source != "Example" || item.Language != "fi" || item.Category.EnName != "sports" {
+		t.Error("stored", item)
+	}
+	if item.Date.After(time.Now()) {
+		t.Error("future date kept", item.Date)
+	}
+	if len(changes) != 1 || changes[0].Updated || changes[0].Item.ID != item.ID {
+		t.Error("changes", changes)
+	}
+
+	// Fetching again updates the item instead of adding a copy.
+	channel.Items[0].Title = "Goal again | Example"
+	f.saveNewsItems(channel, feed, nil)
+	items, _ = s.FindItems(context.Background(), store.ItemQuery{})
+	if len(items) != 1 || items[0].Title != "Goal again" || items[0].ID != item.ID {
+		t.Error("after update", items)
+	}
+}
+
+func TestSaveNewsItemsClampsDates(t *testing.T) {
+	s := memory.New()
+	f := New(s)
+	feed := domain.Feed{ID: primitive.NewObjectID(), Name: "Example"}
+	channel := rss.Feed{Items: []*rss.Item{
+		{Title: "Tomorrow", Link: "https://example.com/1", GUID: "https://example.com/1", Date: time.Now().Add(24 * time.Hour)},
+	}}
+	f.saveNewsItems(channel, feed, nil)
+	items, _ := s.FindItems(context.Background(), store.ItemQuery{})
+	if len(items) != 1 || items[0].Date.After(time.Now()) {
+		t.Error("stored", items)
+	}
+}
+
+func TestSaveNewsItemsBlocked(t *testing.T) {
+	s := memory.New()
+	f := New(s)
+	feed := domain.Feed{ID: primitive.NewObjectID(), Name: "Example"}
+	channel := rss.Feed{Items: []*rss.Item{
+		{Title: "Spam offer", Link: "https://example.com/1", GUID: "https://example.com/1", Date: time.Now()},
+		{Title: "News", Link: "https://example.com/2", GUID: "https://example.com/2", Date: time.Now()},
+	}}
+	rules := []domain.BlockRule{{ID: primitive.NewObjectID(), Kind: domain.BlockKeyword, Value: "spam", Reason: "spam"}}
+	if changes := f.saveNewsItems(channel, feed, rules); len(changes) != 1 || changes[0].Item.Title != "News" {
+		t.Error("changes", changes)
+	}
+}
+
+func TestSaveNewsItemsInvalidFeed(t *testing.T) {
+	s := memory.New()
+	f := New(s)
+	channel := rss.Feed{Items: []*rss.Item{
+		{Title: "Daily horoscope", Link: "https://example.com/1", GUID: "https://example.com/1", Date: time.Now()},
+	}}
+	for name, feed := range map[string]domain.Feed{
+		"processor": {ID: primitive.NewObjectID(), Name: "Example", Processors: []domain.ProcessorConfig{{Name: "unknown"}}},
+		"rule":      {ID: primitive.NewObjectID(), Name: "Example", Exclude: []domain.FilterRule{{Field: "title", Pattern: "(horoscope"}}},
+	} {
+		f.saveNewsItems(channel, feed, nil)
+		if items, _ := s.FindItems(context.Background(), store.ItemQuery{}); len(items) != 0 {
+			t.Error(name, "stored", items)
+		}
+	}
+}
+
+func TestHideAddedBlockRules(t *testing.T) {
+	s := memory.New()
+	f := New(s)
+	ctx := context.Background()
+	changes, err := s.SaveItems(ctx, []rss.Item{
+		{Title: "Spam offer", Link: "https://example.com/1", GUID: "example.com/1", Date: time.Now()},
+		{Title: "News", Link: "https://example.com/2", GUID: "example.com/2", Date: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The rule is added after the feed's rules were read.
+	rule := domain.BlockRule{ID: primitive.NewObjectID(), Kind: domain.BlockKeyword, Value: "spam", Reason: "spam"}
+	if err := s.SaveBlockRule(ctx, rule); err != nil {
+		t.Fatal(err)
+	}
+	visible := f.hideAdded(changes, nil)
+	if len(visible) != 1 || visible[0].Item.Title != "News" {
+		t.Error("visible", visible)
+	}
+	if items, _ := s.FindItems(ctx, store.ItemQuery{}); len(items) != 1 || items[0].Title != "News" {
+		t.Error("listed", items)
+	}
+}
@@ -0,0 +1,308 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/jelinden/rssfetcher/app/admin"
+	"github.com/jelinden/rssfetcher/app/domain"
+	"github.com/jelinden/rssfetcher/app/jobs"
+	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const maxBodySize = 1 << 20
+
+type apiError struct {
+	Error string `json:"error"`
+	Field string `json:"field,omitempty"`
+}
+
+func (h *Handler) ListFeedsHandler(w http.ResponseWriter, r *http.Request) {
+	feeds, err := h.Store.GetFeeds(r.Context(), r.URL.Query().Get("active") == "true")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, feeds)
+}
+
+func (h *Handler) GetFeedHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathObjectID(w, r)
+	if !ok {
+		return
+	}
+	feed, err := h.Store.GetFeed(r.Context(), id.Hex())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, feed)
+}
+
+func (h *Handler) CreateFeedHandler(w http.ResponseWriter, r *http.Request) {
+	var feed domain.Feed
+	if !readJSON(w, r, &feed) {
+		return
+	}
+	feed.ID = primitive.NilObjectID
+	feed, err := h.Admin.SaveFeed(r.Context(), feed)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/feeds/"+feed.ID.Hex())
+	writeJSONStatus(w, http.StatusCreated, feed)
+}
+
+func (h *Handler) UpdateFeedHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathObjectID(w, r)
+	if !ok {
+		return
+	}
+	if _, err := h.Store.GetFeed(r.Context(), id.Hex()); err != nil {
+		writeError(w, err)
+		return
+	}
+	var feed domain.Feed
+	if !readJSON(w, r, &feed) {
+		return
+	}
+	feed.ID = id
+	feed, err := h.Admin.SaveFeed(r.Context(), feed)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, feed)
+}
+
+func (h *Handler) DeleteFeedHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathObjectID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Admin.RemoveFeed(r.Context(), id.Hex()); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) ListCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.Store.GetCategories(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, categories)
+}
+
+func (h *Handler) GetCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathObjectID(w, r)
+	if !ok {
+		return
+	}
+	category, err := h.Store.GetCategoryByID(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, category)
+}
+
+func (h *Handler) CreateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	var category rss.Category
+	if !readJSON(w, r, &category) {
+		return
+	}
+	category, err := h.Admin.CreateCategory(r.Context(), category)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/categories/"+category.ID.Hex())
+	writeJSONStatus(w, http.StatusCreated, category)
+}
+
+// UpdateCategoryAPIHandler answers 202 with the job updating the feeds and
+// items in the category.
+func (h *Handler) UpdateCategoryAPIHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathObjectID(w, r)
+	if !ok {
+		return
+	}
+	var category rss.Category
+	if !readJSON(w, r, &category) {
+		return
+	}
+	category.ID = id
+	job, err := h.Admin.UpdateCategory(r.Context(), category)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJobStarted(w, job)
+}
+
+// DeleteCategoryAPIHandler deletes an unused category, or merges it into
+// the category given with ?target= in a job.
+func (h *Handler) DeleteCategoryAPIHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathObjectID(w, r)
+	if !ok {
+		return
+	}
+	target, err := formObjectID(r, "target")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	job, err := h.Admin.DeleteCategory(r.Context(), id, target)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJobStarted(w, job)
+}
+
+func (h *Handler) ListSubCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	subCategories, err := h.Store.GetSubCategories(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if parent := r.URL.Query().Get("parentId"); parent != "" {
+		filtered := []rss.SubCategory{}
+		for _, subCategory := range subCategories {
+			if subCategory.ParentID.Hex() == parent {
+				filtered = append(filtered, subCategory)
+			}
+		}
+		subCategories = filtered
+	}
+	writeJSON(w, subCategories)
+}
+
+func (h *Handler) GetSubCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathObjectID(w, r)
+	if !ok {
+		return
+	}
+	subCategory, err := h.Store.GetSubCategoryByID(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, subCategory)
+}
+
+func (h *Handler) CreateSubCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	var subCategory rss.SubCategory
+	if !readJSON(w, r, &subCategory) {
+		return
+	}
+	subCategory, err := h.Admin.CreateSubCategory(r.Context(), subCategory)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/subcategories/"+subCategory.ID.Hex())
+	writeJSONStatus(w, http.StatusCreated, subCategory)
+}
+
+func (h *Handler) UpdateSubCategoryAPIHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathObjectID(w, r)
+	if !ok {
+		return
+	}
+	var subCategory rss.SubCategory
+	if !readJSON(w, r, &subCategory) {
+		return
+	}
+	subCategory.ID = id
+	job, err := h.Admin.UpdateSubCategory(r.Context(), subCategory)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJobStarted(w, job)
+}
+
+func (h *Handler) DeleteSubCategoryAPIHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathObjectID(w, r)
+	if !ok {
+		return
+	}
+	target, err := formObjectID(r, "target")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	job, err := h.Admin.DeleteSubCategory(r.Context(), id, target)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJobStarted(w, job)
+}
+
+func (h *Handler) JobHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, store.ErrNotFound)
+		return
+	}
+	job, ok := h.Admin.Jobs.Get(id)
+	if !ok {
+		writeError(w, store.ErrNotFound)
+		return
+	}
+	writeJSON(w, job)
+}
+
+// writeJobStarted answers 202 pointing to the job, or 204 when the change
+// needed no job.
+func writeJobStarted(w http.ResponseWriter, job jobs.Job) {
+	if job.ID == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/jobs/"+strconv.Itoa(job.ID))
+	writeJSONStatus(w, http.StatusAccepted, job)
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(v); err != nil {
+		writeJSONStatus(w, http.StatusBadRequest, apiError{Error: "invalid JSON body: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+func pathObjectID(w http.ResponseWriter, r *http.Request) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		writeError(w, store.ErrNotFound)
+		return id, false
+	}
+	return id, true
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var invalid *admin.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		writeJSONStatus(w, http.StatusUnprocessableEntity, apiError{Error: invalid.Message, Field: invalid.Field})
+	case errors.Is(err, store.ErrNotFound):
+		writeJSONStatus(w, http.StatusNotFound, apiError{Error: err.Error()})
+	case errors.Is(err, store.ErrDuplicate):
+		writeJSONStatus(w, http.StatusConflict, apiError{Error: err.Error()})
+	default:
+		log.Println("api request failed", err)
+		writeJSONStatus(w, http.StatusInternalServerError, apiError{Error: "internal error"})
+	}
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/jelinden/rssfetcher/app/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (h *Handler) BlocklistHandler(w http.ResponseWriter, r *http.Request) {
+	render(w, "blocklist", h.blocklistPage(r.Context(), domain.BlockRule{}, ""))
+}
+
+// AddBlockRuleHandler adds a global block rule, hiding the stored items it
+// matches before returning to the blocklist.
+func (h *Handler) AddBlockRuleHandler(w http.ResponseWriter, r *http.Request) {
+	rule := domain.BlockRule{
+		Kind:   r.FormValue("kind"),
+		Value:  r.FormValue("value"),
+		Reason: r.FormValue("reason"),
+	}
+	saved, err := h.Admin.AddBlockRule(r.Context(), rule)
+	if err != nil {
+		log.Println("adding block rule failed", err)
+		w.WriteHeader(http.StatusBadRequest)
+		render(w, "blocklist", h.blocklistPage(r.Context(), rule, err.Error()))
+		return
+	}
+	log.Println("block rule", saved.Kind, saved.Value, "hid", saved.HiddenItems, "items")
+	http.Redirect(w, r, "/blocklist", http.StatusFound)
+}
+
+func (h *Handler) DeleteBlockRuleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	shown, err := h.Admin.DeleteBlockRule(r.Context(), id)
+	if err != nil {
+		log.Println("deleting block rule failed", err)
+		http.NotFound(w, r)
+		return
+	}
+	log.Println("deleted block rule", id.Hex(), "showing", shown, "items again")
+	http.Redirect(w, r, "/blocklist", http.StatusFound)
+}
+
+func (h *Handler) blocklistPage(ctx context.Context, rule domain.BlockRule, message string) domain.BlocklistPage {
+	page := domain.BlocklistPage{Rule: rule, Kinds: domain.BlockKinds, Error: message}
+	var err error
+	if page.Rules, err = h.Store.GetBlockRules(ctx); err != nil {
+		log.Println(err)
+	}
+	return page
+}
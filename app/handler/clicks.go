@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GoHandler counts a click on an item and redirects to the item's link.
+func (h *Handler) GoHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	item, err := h.Store.GetItem(r.Context(), id.Hex())
+	if err != nil || item.Hidden {
+		http.NotFound(w, r)
+		return
+	}
+	link, err := url.Parse(item.Link)
+	if err != nil || (link.Scheme != "http" && link.Scheme != "https") {
+		http.NotFound(w, r)
+		return
+	}
+	if h.Clicks != nil {
+		if _, err := h.Clicks.Click(r.Context(), id, h.clientIP(r), r.UserAgent()); err != nil {
+			log.Println("counting click failed", id.Hex(), err)
+		}
+	}
+	// Cached redirects would skip the counting.
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, link.String(), http.StatusFound)
+}
+
+// clientIP is the address the request came from, or the one the proxy in
+// front of us appended to X-Forwarded-For.
+func (h *Handler) clientIP(r *http.Request) string {
+	if h.BehindProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			hops := strings.Split(forwarded, ",")
+			return strings.TrimSpace(hops[len(hops)-1])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -1,98 +1,409 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
 
+	"github.com/jelinden/rssfetcher/app/admin"
+	"github.com/jelinden/rssfetcher/app/clicks"
 	"github.com/jelinden/rssfetcher/app/domain"
-	"github.com/jelinden/rssfetcher/app/mongo"
+	"github.com/jelinden/rssfetcher/app/icon"
+	"github.com/jelinden/rssfetcher/app/process"
 	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/rules"
+	"github.com/jelinden/rssfetcher/app/store"
+	"github.com/jelinden/rssfetcher/app/stream"
+	"github.com/jelinden/rssfetcher/app/trending"
+	"github.com/jelinden/rssfetcher/public"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-var templates = template.Must(template.ParseGlob("public/tmpl/*"))
+var templates = template.Must(template.ParseFS(public.Templates, "tmpl/*.html"))
 
-func ViewHandler(w http.ResponseWriter, r *http.Request) {
-	feedList := mongo.GetFeeds(false)
-	categoryList := mongo.GetCategories()
-	subCategoryList := mongo.GetSubCategories()
+type Handler struct {
+	Store  store.Store
+	Admin  *admin.Service
+	Clicks *clicks.Tracker
+	// Trending serves /api/v1/trending, nil disables it.
+	Trending *trending.Ranker
+	// Stream serves /api/v1/stream, nil disables it.
+	Stream *stream.Hub
+	// BehindProxy takes client addresses from X-Forwarded-For.
+	BehindProxy bool
+}
+
+func New(s store.Store, a *admin.Service) *Handler {
+	return &Handler{Store: s, Admin: a}
+}
+
+func (h *Handler) ViewHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	feedList, err := h.Store.GetFeeds(ctx, false)
+	if err != nil {
+		log.Println("getting feeds failed", err)
+	}
+	categoryList, err := h.Store.GetCategories(ctx)
+	if err != nil {
+		log.Println("getting categories failed", err)
+	}
+	subCategoryList, err := h.Store.GetSubCategories(ctx)
+	if err != nil {
+		log.Println("getting subcategories failed", err)
+	}
 	viewPage := domain.ViewPage{}
 	viewPage.Feeds = feedList
 	viewPage.Categories = categoryList
 	viewPage.SubCategories = subCategoryList
+	viewPage.Jobs = h.Admin.Jobs.List()
 	renderViewTemplate(w, "view", &viewPage)
 }
 
-func EditHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) EditHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
 	fmt.Println("editing", r.URL.Path)
-	feed, err := mongo.GetFeed(r.URL.Path[6:])
-	editPage := domain.EditPage{}
+	feed, err := h.Store.GetFeed(ctx, r.URL.Path[6:])
 	if err != nil {
 		feed = &domain.Feed{ID: primitive.NewObjectID()}
 	}
+	renderTemplate(w, "edit", h.editPage(ctx, *feed, ""))
+}
+
+func (h *Handler) editPage(ctx context.Context, feed domain.Feed, message string) domain.EditPage {
+	var err error
 	if feed.SubCategory == nil {
 		feed.SubCategory = &rss.SubCategory{SubCategory: ""}
 	}
-	editPage.Feed = *feed
-	editPage.Categories = mongo.GetCategories()
-	editPage.SubCategories = mongo.GetSubCategories()
-	renderTemplate(w, "edit", editPage)
+	editPage := domain.EditPage{Feed: feed, Error: message,
+		Include:    rules.Format(feed.Include),
+		Exclude:    rules.Format(feed.Exclude),
+		Processors: process.Format(feed.Processors)}
+	if message == "" {
+		if editPage.DryRun, err = h.Admin.DryRunRules(ctx, feed); err != nil {
+			editPage.Error = err.Error()
+		}
+	}
+	editPage.Categories, err = h.Store.GetCategories(ctx)
+	if err != nil {
+		log.Println("getting categories failed", err)
+	}
+	editPage.SubCategories, err = h.Store.GetSubCategories(ctx)
+	if err != nil {
+		log.Println("getting subcategories failed", err)
+	}
+	return editPage
 }
 
-func RemoveHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) RemoveHandler(w http.ResponseWriter, r *http.Request) {
 	id := strings.TrimPrefix(r.URL.Path, "/delete/")
-	feed, err := mongo.GetFeed(id)
-	if err != nil {
-		log.Println("error getting", id, err)
-	} else {
-		feed.Removed = true
-		log.Println("saving", feed.ID.Hex(), feed.Name, feed.Removed)
-		mongo.SaveFeedItem(*feed)
+	if err := h.Admin.RemoveFeed(r.Context(), id); err != nil {
+		log.Println("removing feed failed", id, err)
 	}
 	http.Redirect(w, r, "/view/", http.StatusFound)
 }
 
-func SaveHandler(w http.ResponseWriter, r *http.Request) {
-	feed, err := mongo.GetFeed(r.URL.Path[6:])
+func (h *Handler) SaveHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, _ := primitive.ObjectIDFromHex(r.URL.Path[6:])
+	feed := domain.Feed{ID: id,
+		Name:         r.FormValue("name"),
+		URL:          r.FormValue("url"),
+		SiteURL:      r.FormValue("siteUrl"),
+		Language:     r.FormValue("language"),
+		OGImage:      r.FormValue("ogImage") == "on",
+		ResolveLinks: r.FormValue("resolveLinks") == "on",
+	}
+	if name := r.FormValue("category"); name != "0" {
+		feed.Category.Name = name
+	}
+	if name := r.FormValue("subCategory"); name != "" && name != "0" {
+		feed.SubCategory = &rss.SubCategory{SubCategory: name}
+	}
+	err := feedRulesForm(r, &feed)
+	if err == nil && r.FormValue("dryRun") != "" {
+		// Shows what the edited rules would drop without saving them.
+		renderTemplate(w, "edit", h.editPage(ctx, feed, ""))
+		return
+	}
+	saved := feed
+	if err == nil {
+		saved, err = h.Admin.SaveFeed(ctx, feed)
+	}
 	if err != nil {
-		log.Println("error getting feed", err.Error())
+		log.Println("saving feed failed", err)
+		w.WriteHeader(http.StatusBadRequest)
+		page := h.editPage(ctx, saved, err.Error())
+		page.Include, page.Exclude = r.FormValue("include"), r.FormValue("exclude")
+		page.Processors = r.FormValue("processors")
+		renderTemplate(w, "edit", page)
+		return
 	}
-	lang := r.FormValue("language")
-	category := mongo.GetCategory(r.FormValue("category"))
-	if category.ID.IsZero() {
-		category.ID = primitive.NewObjectID()
+	http.Redirect(w, r, "/view/", http.StatusFound)
+}
+
+func feedRulesForm(r *http.Request, feed *domain.Feed) error {
+	var err error
+	if feed.Include, err = rules.Parse("include", r.FormValue("include")); err != nil {
+		return err
+	}
+	if feed.Exclude, err = rules.Parse("exclude", r.FormValue("exclude")); err != nil {
+		return err
 	}
-	subCategory := mongo.GetSubCategory(r.FormValue("subCategory"))
-	if subCategory.ID.IsZero() {
-		subCategory.ID = primitive.NewObjectID()
+	if feed.Processors, err = process.Parse(r.FormValue("processors")); err != nil {
+		return err
+	}
+	if value := strings.TrimSpace(r.FormValue("minTitleLength")); value != "" {
+		if feed.MinTitleLength, err = strconv.Atoi(value); err != nil {
+			return &admin.ValidationError{Field: "minTitleLength", Message: "must be a number"}
+		}
+	}
+	return nil
+}
+
+func (h *Handler) SaveCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	category := rss.Category{
+		Name:      r.FormValue("categoryName"),
+		EnName:    r.FormValue("enName"),
+		StyleName: r.FormValue("styleName")}
+	if _, err := h.Admin.CreateCategory(r.Context(), category); err != nil {
+		log.Println("saving category failed", err)
 	}
-	name := r.FormValue("name")
-	url := r.FormValue("url")
-	siteURL := r.FormValue("siteUrl")
-	mongo.SaveFeed(feed, lang, name, url, siteURL, category, subCategory)
 	http.Redirect(w, r, "/view/", http.StatusFound)
 }
 
-func SaveCategoryHandler(w http.ResponseWriter, r *http.Request) {
-	category := rss.Category{ID: primitive.NewObjectID(),
+func (h *Handler) SaveSubCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	parentID, _ := primitive.ObjectIDFromHex(r.FormValue("parentId"))
+	subCategory := rss.SubCategory{
+		SubCategory: r.FormValue("subCategory"),
+		EnName:      r.FormValue("enName"),
+		ParentID:    parentID,
+	}
+	if _, err := h.Admin.CreateSubCategory(r.Context(), subCategory); err != nil {
+		log.Println("saving subcategory failed", err)
+	}
+	http.Redirect(w, r, "/view/", http.StatusFound)
+}
+
+func (h *Handler) EditCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	category, err := h.Store.GetCategoryByID(r.Context(), id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	render(w, "category", h.categoryPage(r.Context(), category, ""))
+}
+
+func (h *Handler) UpdateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	category := rss.Category{ID: id,
 		Name:      r.FormValue("categoryName"),
 		EnName:    r.FormValue("enName"),
 		StyleName: r.FormValue("styleName")}
-	mongo.SaveCategory(category)
+	if _, err := h.Admin.UpdateCategory(r.Context(), category); err != nil {
+		log.Println("updating category failed", err)
+		w.WriteHeader(http.StatusBadRequest)
+		render(w, "category", h.categoryPage(r.Context(), category, err.Error()))
+		return
+	}
+	http.Redirect(w, r, "/view/", http.StatusFound)
+}
+
+func (h *Handler) DeleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	category, err := h.Store.GetCategoryByID(r.Context(), id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	target, err := formObjectID(r, "target")
+	if err == nil {
+		_, err = h.Admin.DeleteCategory(r.Context(), id, target)
+	}
+	if err != nil {
+		log.Println("deleting category failed", err)
+		w.WriteHeader(http.StatusBadRequest)
+		render(w, "category", h.categoryPage(r.Context(), category, err.Error()))
+		return
+	}
 	http.Redirect(w, r, "/view/", http.StatusFound)
 }
 
-func SaveSubCategoryHandler(w http.ResponseWriter, r *http.Request) {
-	subCategory := rss.SubCategory{ID: primitive.NewObjectID(),
+func (h *Handler) EditSubCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	subCategory, err := h.Store.GetSubCategoryByID(r.Context(), id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	render(w, "subcategory", h.subCategoryPage(r.Context(), subCategory, ""))
+}
+
+func (h *Handler) UpdateSubCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	parentID, _ := primitive.ObjectIDFromHex(r.FormValue("parentId"))
+	subCategory := rss.SubCategory{ID: id,
 		SubCategory: r.FormValue("subCategory"),
+		EnName:      r.FormValue("enName"),
+		ParentID:    parentID}
+	if _, err := h.Admin.UpdateSubCategory(r.Context(), subCategory); err != nil {
+		log.Println("updating subcategory failed", err)
+		w.WriteHeader(http.StatusBadRequest)
+		render(w, "subcategory", h.subCategoryPage(r.Context(), subCategory, err.Error()))
+		return
+	}
+	http.Redirect(w, r, "/view/", http.StatusFound)
+}
+
+func (h *Handler) DeleteSubCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	subCategory, err := h.Store.GetSubCategoryByID(r.Context(), id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	target, err := formObjectID(r, "target")
+	if err == nil {
+		_, err = h.Admin.DeleteSubCategory(r.Context(), id, target)
+	}
+	if err != nil {
+		log.Println("deleting subcategory failed", err)
+		w.WriteHeader(http.StatusBadRequest)
+		render(w, "subcategory", h.subCategoryPage(r.Context(), subCategory, err.Error()))
+		return
 	}
-	mongo.SaveSubCategory(subCategory)
 	http.Redirect(w, r, "/view/", http.StatusFound)
 }
 
+func (h *Handler) categoryPage(ctx context.Context, category rss.Category, message string) domain.CategoryPage {
+	page := domain.CategoryPage{Category: category, Error: message}
+	categories, err := h.Store.GetCategories(ctx)
+	if err != nil {
+		log.Println(err)
+	}
+	for _, c := range categories {
+		if c.ID != category.ID {
+			page.Targets = append(page.Targets, c)
+		}
+	}
+	return page
+}
+
+func (h *Handler) subCategoryPage(ctx context.Context, subCategory rss.SubCategory, message string) domain.SubCategoryPage {
+	page := domain.SubCategoryPage{SubCategory: subCategory, Error: message}
+	categories, err := h.Store.GetCategories(ctx)
+	if err != nil {
+		log.Println(err)
+	}
+	page.Categories = categories
+	subCategories, err := h.Store.GetSubCategories(ctx)
+	if err != nil {
+		log.Println(err)
+	}
+	for _, s := range subCategories {
+		if s.ID != subCategory.ID && s.ParentID == subCategory.ParentID {
+			page.Targets = append(page.Targets, s)
+		}
+	}
+	return page
+}
+
+// formObjectID parses an optional ObjectID form value, zero when empty.
+func formObjectID(r *http.Request, key string) (primitive.ObjectID, error) {
+	value := r.FormValue(key)
+	if value == "" {
+		return primitive.NilObjectID, nil
+	}
+	id, err := primitive.ObjectIDFromHex(value)
+	if err != nil {
+		return id, &admin.ValidationError{Field: key, Message: "is not a valid id"}
+	}
+	return id, nil
+}
+
+func (h *Handler) IconHandler(w http.ResponseWriter, r *http.Request) {
+	path := icon.Path(strings.TrimPrefix(r.URL.Path, "/icons/"))
+	if _, err := os.Stat(path); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	http.ServeFile(w, r, path)
+}
+
+type clusterResponse struct {
+	ID             string     `json:"id"`
+	Representative *rss.Item  `json:"representative"`
+	Members        []rss.Item `json:"members"`
+}
+
+func (h *Handler) ClusterHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	members, err := h.Store.FindItems(r.Context(), store.ItemQuery{ClusterID: id})
+	if err != nil {
+		log.Println("getting cluster failed", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(members) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	response := clusterResponse{ID: id.Hex(), Members: members}
+	for i := range members {
+		if members[i].Representative {
+			response.Representative = &members[i]
+		}
+	}
+	writeJSON(w, response)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	writeJSONStatus(w, http.StatusOK, v)
+}
+
+func writeJSONStatus(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("writing json failed", err)
+	}
+}
+
 func renderTemplate(w http.ResponseWriter, tmpl string, f domain.EditPage) {
 	err := templates.ExecuteTemplate(w, tmpl, f)
 	if err != nil {
@@ -100,6 +411,13 @@ func renderTemplate(w http.ResponseWriter, tmpl string, f domain.EditPage) {
 	}
 }
 
+func render(w http.ResponseWriter, tmpl string, data interface{}) {
+	err := templates.ExecuteTemplate(w, tmpl, data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 func renderViewTemplate(w http.ResponseWriter, tmpl string, f *domain.ViewPage) {
 	err := templates.ExecuteTemplate(w, tmpl, f)
 	if err != nil {
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jelinden/rssfetcher/app/admin"
+	"github.com/jelinden/rssfetcher/app/jobs"
+	"github.com/jelinden/rssfetcher/app/memory"
+	"github.com/jelinden/rssfetcher/app/rss"
+)
+
+func newTestHandler(t *testing.T) (*Handler, *memory.Repository) {
+	t.Helper()
+	s := memory.New()
+	return New(s, admin.New(s, jobs.NewRunner())), s
+}
+
+func postForm(handler http.HandlerFunc, path string, form url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func get(handler http.HandlerFunc, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestSaveCategoryHandler(t *testing.T) {
+	h, s := newTestHandler(t)
+	w := postForm(h.SaveCategoryHandler, "/category", url.Values{"categoryName": {"Urheilu"}, "enName": {"sports"}})
+	if w.Code != http.StatusFound {
+		t.Fatal("status", w.Code)
+	}
+	category, err := s.GetCategory(context.Background(), "Urheilu")
+	if err != nil || category.EnName != "sports" || category.ID.IsZero() {
+		t.Error(category, err)
+	}
+}
+
+func TestFeedFlow(t *testing.T) {
+	h, s := newTestHandler(t)
+	ctx := context.Background()
+	if err := s.SaveCategory(ctx, rss.Category{Name: "Urheilu", EnName: "sports"}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := postForm(h.SaveHandler, "/save/", url.Values{
+		"name": {"Example"}, "url": {"https://example.com/rss"}, "language": {"fi"}, "category": {"Urheilu"},
+		"exclude": {"title: (?i)horoscope"}, "processors": {"decodeEntities"},
+	})
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/view/" {
+		t.Fatal("save", w.Code, w.Body.String())
+	}
+	feeds, _ := s.GetFeeds(ctx, false)
+	if len(feeds) != 1 {
+		t.Fatal("feeds", feeds)
+	}
+	feed := feeds[0]
+	if feed.Name != "Example" || feed.Category.EnName != "sports" || len(feed.Exclude) != 1 || len(feed.Processors) != 1 {
+		t.Error("saved", feed)
+	}
+
+	w = get(h.EditHandler, "/edit/"+feed.ID.Hex())
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "https://example.com/rss") ||
+		!strings.Contains(w.Body.String(), "title: (?i)horoscope") {
+		t.Error("edit", w.Code, w.Body.String())
+	}
+
+	w = postForm(h.SaveHandler, "/save/"+feed.ID.Hex(), url.Values{
+		"name": {"Example"}, "url": {"not a url"}, "category": {"Urheilu"}, "exclude": {"title: ("},
+	})
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "url: must be an http or https URL") {
+		t.Error("invalid save", w.Code, w.Body.String())
+	}
+	w = postForm(h.SaveHandler, "/save/"+feed.ID.Hex(), url.Values{
+		"name": {"Example"}, "url": {"https://example.com/rss"}, "category": {"Urheilu"}, "exclude": {"title: ("},
+	})
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "exclude: rule 1") ||
+		!strings.Contains(w.Body.String(), "title: (") {
+		t.Error("invalid rule", w.Code, w.Body.String())
+	}
+	if stored, _ := s.GetFeed(ctx, feed.ID.Hex()); len(stored.Exclude) != 1 || stored.Exclude[0].Pattern != "(?i)horoscope" {
+		t.Error("invalid save changed the feed", stored.Exclude)
+	}
+
+	w = get(h.RemoveHandler, "/delete/"+feed.ID.Hex())
+	if w.Code != http.StatusFound {
+		t.Error("delete", w.Code)
+	}
+	if active, _ := s.GetFeeds(ctx, true); len(active) != 0 {
+		t.Error("removed feed is active", active)
+	}
+	w = get(h.ViewHandler, "/view/")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Example") {
+		t.Error("view", w.Code)
+	}
+}
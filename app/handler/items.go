@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/admin"
+	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultItemLimit = 20
+	maxItemLimit     = 100
+	// itemsCacheControl lets browsers and CDNs reuse item listings for a
+	// minute, about how often new items are fetched.
+	itemsCacheControl = "public, max-age=60"
+)
+
+type itemsResponse struct {
+	Items      []rss.Item `json:"items"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+// itemCursor is handed to clients base64 encoded. It remembers the order it
+// was made for, as the position means nothing in another one.
+type itemCursor struct {
+	Order  string             `json:"o"`
+	Date   time.Time          `json:"d"`
+	Clicks int                `json:"c"`
+	ID     primitive.ObjectID `json:"i"`
+}
+
+// ItemsHandler lists news items, newest or most clicked first. A listing
+// longer than limit has a nextCursor to pass as cursor for the next page.
+func (h *Handler) ItemsHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	q, invalid := parseItemQuery(params)
+	if invalid != nil {
+		writeJSONStatus(w, http.StatusBadRequest, apiError{Error: invalid.Message, Field: invalid.Field})
+		return
+	}
+	limit := q.Limit
+	q.Limit++
+	items, err := h.Store.FindItems(r.Context(), q)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	withIcons(items)
+	response := itemsResponse{Items: items}
+	if len(items) > limit {
+		response.Items = items[:limit]
+		response.NextCursor = encodeCursor(itemOrder(params), items[limit-1])
+	}
+	body, err := json.Marshal(response)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", itemsCacheControl)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(body); err != nil {
+		log.Println("writing items failed", err)
+	}
+}
+
+func parseItemQuery(params url.Values) (store.ItemQuery, *admin.ValidationError) {
+	q := store.ItemQuery{
+		Category:    params.Get("category"),
+		SubCategory: params.Get("subcategory"),
+		Language:    params.Get("language"),
+		Source:      params.Get("source"),
+		Text:        strings.TrimSpace(params.Get("q")),
+		Limit:       defaultItemLimit,
+	}
+	if feed := params.Get("feed"); feed != "" {
+		id, err := primitive.ObjectIDFromHex(feed)
+		if err != nil {
+			return q, &admin.ValidationError{Field: "feed", Message: "must be a feed id"}
+		}
+		q.FeedID = id
+	}
+	for name, t := range map[string]*time.Time{"after": &q.After, "before": &q.Before} {
+		if value := params.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return q, &admin.ValidationError{Field: name, Message: "must be an RFC 3339 time"}
+			}
+			*t = parsed
+		}
+	}
+	switch params.Get("collapse") {
+	case "", "true":
+		q.Representatives = true
+	case "false":
+	default:
+		return q, &admin.ValidationError{Field: "collapse", Message: "must be true or false"}
+	}
+	order := itemOrder(params)
+	switch order {
+	case "pubDate":
+	case "clicks":
+		q.ByClicks = true
+	default:
+		return q, &admin.ValidationError{Field: "order", Message: "must be pubDate or clicks"}
+	}
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxItemLimit {
+			return q, &admin.ValidationError{Field: "limit", Message: "must be between 1 and " + strconv.Itoa(maxItemLimit)}
+		}
+		q.Limit = n
+	}
+	if cursor := params.Get("cursor"); cursor != "" {
+		c, err := decodeCursor(cursor)
+		if err != nil || c.Order != order {
+			return q, &admin.ValidationError{Field: "cursor", Message: "is not a cursor for this order"}
+		}
+		q.Cursor = &store.ItemCursor{Date: c.Date, Clicks: c.Clicks, ID: c.ID}
+	}
+	return q, nil
+}
+
+func itemOrder(params url.Values) string {
+	if order := params.Get("order"); order != "" {
+		return order
+	}
+	return "pubDate"
+}
+
+func encodeCursor(order string, item rss.Item) string {
+	c := store.CursorOf(item)
+	b, err := json.Marshal(itemCursor{Order: order, Date: c.Date, Clicks: c.Clicks, ID: c.ID})
+	if err != nil {
+		log.Println("encoding cursor failed", err)
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (itemCursor, error) {
+	var c itemCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	return c, json.Unmarshal(b, &c)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func withIcons(items []rss.Item) {
+	for i := range items {
+		items[i].Icon = iconURL(items[i])
+	}
+}
+
+// iconURL is where the icon of the item's feed is served, empty for items
+// without a feed.
+func iconURL(item rss.Item) string {
+	if item.FeedID.IsZero() {
+		return ""
+	}
+	return "/icons/" + item.FeedID.Hex()
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestItemsHandlerCollapsesClusters(t *testing.T) {
+	h, s := newTestHandler(t)
+	ctx := context.Background()
+	var items []rss.Item
+	for _, guid := range []string{"1", "2", "3"} {
+		items = append(items, rss.Item{Title: "Story " + guid, Link: "https://example.com/" + guid, GUID: guid, Date: time.Now()})
+	}
+	if _, err := s.SaveItems(ctx, items); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := s.FindItems(ctx, store.ItemQuery{Oldest: true})
+	cluster := primitive.NewObjectID()
+	if err := s.SetClusters(ctx, []store.ClusterUpdate{
+		{ItemID: stored[0].ID, ClusterID: cluster, Representative: true},
+		{ItemID: stored[1].ID, ClusterID: cluster},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]struct{ code, items int }{
+		"/api/v1/items":                {http.StatusOK, 2},
+		"/api/v1/items?collapse=false": {http.StatusOK, 3},
+		"/api/v1/items?collapse=maybe": {http.StatusBadRequest, 0},
+	} {
+		w := get(h.ItemsHandler, path)
+		var response itemsResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		if w.Code != want.code || len(response.Items) != want.items {
+			t.Error(path, w.Code, len(response.Items))
+		}
+	}
+}
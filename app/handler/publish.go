@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/publish"
+	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/store"
+)
+
+const (
+	publishedItems       = 50
+	publishedCacheMaxAge = "public, max-age=300"
+)
+
+type publishFormat struct {
+	contentType string
+	write       func(publish.Channel, []rss.Item) ([]byte, error)
+}
+
+var publishFormats = map[string]publishFormat{
+	".rss":  {"application/rss+xml; charset=utf-8", publish.RSS},
+	".atom": {"application/atom+xml; charset=utf-8", publish.Atom},
+	".json": {"application/feed+json; charset=utf-8", publish.JSONFeed},
+}
+
+// PublishHandler serves the latest items of a category as RSS, Atom or JSON
+// Feed, e.g. /feeds/sports.rss?language=fi&subcategory=football. The
+// category is matched on its enName or name.
+func (h *Handler) PublishHandler(w http.ResponseWriter, r *http.Request) {
+	file := r.PathValue("file")
+	format, ok := publishFormats[path.Ext(file)]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	name := strings.TrimSuffix(file, path.Ext(file))
+	categories, err := h.Store.GetCategories(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var category *rss.Category
+	for i := range categories {
+		if categories[i].EnName == name || categories[i].Name == name {
+			category = &categories[i]
+			break
+		}
+	}
+	if category == nil {
+		http.NotFound(w, r)
+		return
+	}
+	params := r.URL.Query()
+	items, err := h.Store.FindItems(r.Context(), store.ItemQuery{
+		Category:    name,
+		SubCategory: params.Get("subcategory"),
+		Language:    params.Get("language"),
+		// A story is republished once, as its representative.
+		Representatives: true,
+		Limit:           publishedItems,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	base := baseURL(r)
+	channel := publish.Channel{
+		Title:       category.Name,
+		Description: "Latest news in " + category.Name,
+		Link:        base + "/",
+		FeedURL:     base + r.URL.RequestURI(),
+		Language:    params.Get("language"),
+	}
+	if sub := params.Get("subcategory"); sub != "" {
+		channel.Title += " / " + sub
+	}
+	for _, item := range items {
+		if modified := lastModified(item); modified.After(channel.Updated) {
+			channel.Updated = modified
+		}
+	}
+	body, err := format.write(channel, items)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	sum := sha256.Sum256(body)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Set("Cache-Control", publishedCacheMaxAge)
+	// ServeContent answers If-None-Match and If-Modified-Since.
+	http.ServeContent(w, r, file, channel.Updated, bytes.NewReader(body))
+}
+
+// lastModified is when an item was stored or last changed, its publication
+// date for items stored before that was kept.
+func lastModified(item rss.Item) time.Time {
+	if item.Updated.IsZero() {
+		return item.Date
+	}
+	return item.Updated
+}
+
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
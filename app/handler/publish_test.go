@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/rss"
+)
+
+func TestPublishHandlerValidators(t *testing.T) {
+	h, s := newTestHandler(t)
+	ctx := context.Background()
+	sports := rss.Category{Name: "Urheilu", EnName: "sports"}
+	if err := s.SaveCategory(ctx, sports); err != nil {
+		t.Fatal(err)
+	}
+	newest := rss.Item{Title: "Newest", Link: "https://example.com/1", GUID: "1", Date: time.Now(), Category: sports}
+	if _, err := s.SaveItems(ctx, []rss.Item{newest}); err != nil {
+		t.Fatal(err)
+	}
+	serve := func(header, value string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/feeds/sports.rss", nil)
+		r.SetPathValue("file", "sports.rss")
+		if header != "" {
+			r.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		h.PublishHandler(w, r)
+		return w
+	}
+	first := serve("", "")
+	etag, modified := first.Header().Get("ETag"), first.Header().Get("Last-Modified")
+	if first.Code != http.StatusOK || etag == "" || modified == "" {
+		t.Fatal(first.Code, first.Header())
+	}
+	if w := serve("If-None-Match", etag); w.Code != http.StatusNotModified {
+		t.Error("If-None-Match", w.Code)
+	}
+	if w := serve("If-Modified-Since", modified); w.Code != http.StatusNotModified {
+		t.Error("If-Modified-Since", w.Code)
+	}
+
+	// An item stored later with an older date changes the feed.
+	time.Sleep(time.Second)
+	older := rss.Item{Title: "Older", Link: "https://example.com/2", GUID: "2", Date: time.Now().Add(-time.Hour), Category: sports}
+	if _, err := s.SaveItems(ctx, []rss.Item{older}); err != nil {
+		t.Fatal(err)
+	}
+	w := serve("If-Modified-Since", modified)
+	if w.Code != http.StatusOK || w.Header().Get("Last-Modified") == modified {
+		t.Error("If-Modified-Since after a new item", w.Code, w.Header())
+	}
+	if w := serve("If-None-Match", etag); w.Code != http.StatusOK {
+		t.Error("stale ETag", w.Code)
+	}
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/stream"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// streamHeartbeat keeps idle connections from being closed by proxies.
+const streamHeartbeat = 30 * time.Second
+
+type streamFilter struct {
+	category string
+	language string
+	feedID   primitive.ObjectID
+}
+
+func (f streamFilter) matches(e stream.Event) bool {
+	item := e.Item
+	return (f.category == "" || f.category == item.Category.EnName || f.category == item.Category.Name) &&
+		(f.language == "" || f.language == item.Language) &&
+		(f.feedID.IsZero() || f.feedID == item.FeedID)
+}
+
+// StreamHandler sends inserted and changed items as server-sent events,
+// optionally of a category, language and feed only. A client reconnecting
+// with Last-Event-ID first gets the buffered events it missed.
+func (h *Handler) StreamHandler(w http.ResponseWriter, r *http.Request) {
+	if h.Stream == nil {
+		http.NotFound(w, r)
+		return
+	}
+	params := r.URL.Query()
+	filter := streamFilter{category: params.Get("category"), language: params.Get("language")}
+	if feed := params.Get("feed"); feed != "" {
+		id, err := primitive.ObjectIDFromHex(feed)
+		if err != nil {
+			writeJSONStatus(w, http.StatusBadRequest, apiError{Error: "must be a feed id", Field: "feed"})
+			return
+		}
+		filter.feedID = id
+	}
+	last := h.Stream.LastID()
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		id, err := strconv.ParseUint(header, 10, 64)
+		if err != nil {
+			writeJSONStatus(w, http.StatusBadRequest, apiError{Error: "must be an event id", Field: "Last-Event-ID"})
+			return
+		}
+		last = id
+	}
+	updates := h.Stream.Subscribe()
+	defer h.Stream.Unsubscribe(updates)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		for _, event := range h.Stream.Since(last) {
+			last = event.ID
+			if !filter.matches(event) {
+				continue
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-updates:
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event stream.Event) error {
+	data, err := json.Marshal(event.Item)
+	if err != nil {
+		log.Println("encoding event failed", err)
+		return nil
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
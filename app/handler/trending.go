@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/trending"
+)
+
+type trendingResponse struct {
+	Items   []trending.Entry `json:"items"`
+	Updated time.Time        `json:"updated"`
+}
+
+// TrendingHandler lists the most read items right now, optionally of a
+// category and language. The lists are ranked in the background, so they
+// are cached for as long as the ranking is not redone.
+func (h *Handler) TrendingHandler(w http.ResponseWriter, r *http.Request) {
+	if h.Trending == nil {
+		http.NotFound(w, r)
+		return
+	}
+	params := r.URL.Query()
+	entries, updated := h.Trending.Get(params.Get("category"), params.Get("language"))
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxItemLimit {
+			writeJSONStatus(w, http.StatusBadRequest, apiError{Error: "must be between 1 and " + strconv.Itoa(maxItemLimit), Field: "limit"})
+			return
+		}
+		if n < len(entries) {
+			entries = entries[:n]
+		}
+	}
+	// The ranked lists are shared, the icons are set on a copy.
+	entries = append(make([]trending.Entry, 0, len(entries)), entries...)
+	for i := range entries {
+		entries[i].Item.Icon = iconURL(entries[i].Item)
+	}
+	w.Header().Set("Cache-Control", itemsCacheControl)
+	writeJSON(w, trendingResponse{Items: entries, Updated: updated})
+}
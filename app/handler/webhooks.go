@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/jelinden/rssfetcher/app/domain"
+	"github.com/jelinden/rssfetcher/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const shownDeliveries = 100
+
+func (h *Handler) WebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	render(w, "webhooks", h.webhooksPage(r.Context(), ""))
+}
+
+func (h *Handler) CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	hook, err := webhookForm(r, primitive.NilObjectID)
+	if err == nil {
+		_, err = h.Admin.SaveWebhook(r.Context(), hook)
+	}
+	if err != nil {
+		log.Println("saving webhook failed", err)
+		w.WriteHeader(http.StatusBadRequest)
+		render(w, "webhooks", h.webhooksPage(r.Context(), err.Error()))
+		return
+	}
+	http.Redirect(w, r, "/webhooks", http.StatusFound)
+}
+
+func (h *Handler) EditWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	hook, err := h.Store.GetWebhook(r.Context(), id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	render(w, "webhook", h.webhookPage(r.Context(), *hook, ""))
+}
+
+func (h *Handler) UpdateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	hook, err := webhookForm(r, id)
+	if err == nil {
+		_, err = h.Admin.SaveWebhook(r.Context(), hook)
+	}
+	if err != nil {
+		log.Println("saving webhook failed", err)
+		w.WriteHeader(http.StatusBadRequest)
+		render(w, "webhook", h.webhookPage(r.Context(), hook, err.Error()))
+		return
+	}
+	http.Redirect(w, r, "/webhooks/"+id.Hex(), http.StatusFound)
+}
+
+func (h *Handler) DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := h.Admin.DeleteWebhook(r.Context(), id); err != nil {
+		log.Println("deleting webhook failed", err)
+		http.NotFound(w, r)
+		return
+	}
+	http.Redirect(w, r, "/webhooks", http.StatusFound)
+}
+
+// RetryDeliveryHandler starts a failed delivery again and returns to the
+// page the retry was asked on.
+func (h *Handler) RetryDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := h.Admin.RetryDelivery(r.Context(), id); err != nil {
+		log.Println("retrying delivery failed", err)
+		w.WriteHeader(http.StatusBadRequest)
+		render(w, "webhooks", h.webhooksPage(r.Context(), err.Error()))
+		return
+	}
+	back := "/webhooks"
+	if r.FormValue("back") == "webhook" {
+		if delivery, err := h.Store.GetDelivery(r.Context(), id); err == nil {
+			back += "/" + delivery.WebhookID.Hex()
+		}
+	}
+	http.Redirect(w, r, back, http.StatusFound)
+}
+
+func webhookForm(r *http.Request, id primitive.ObjectID) (domain.Webhook, error) {
+	hook := domain.Webhook{ID: id,
+		URL:    r.FormValue("url"),
+		Secret: r.FormValue("secret"),
+		Filter: domain.WebhookFilter{
+			Category: r.FormValue("category"),
+			Language: r.FormValue("language"),
+			Pattern:  r.FormValue("pattern")}}
+	feedID, err := formObjectID(r, "feedId")
+	hook.Filter.FeedID = feedID
+	return hook, err
+}
+
+func (h *Handler) webhooksPage(ctx context.Context, message string) domain.WebhooksPage {
+	page := domain.WebhooksPage{Error: message}
+	var err error
+	if page.Webhooks, err = h.Store.GetWebhooks(ctx); err != nil {
+		log.Println(err)
+	}
+	if page.Feeds, err = h.Store.GetFeeds(ctx, false); err != nil {
+		log.Println(err)
+	}
+	if page.Categories, err = h.Store.GetCategories(ctx); err != nil {
+		log.Println(err)
+	}
+	page.DeadLetters, err = h.Store.FindDeliveries(ctx, store.DeliveryQuery{Status: domain.DeliveryFailed, Limit: shownDeliveries})
+	if err != nil {
+		log.Println(err)
+	}
+	return page
+}
+
+func (h *Handler) webhookPage(ctx context.Context, hook domain.Webhook, message string) domain.WebhookPage {
+	page := domain.WebhookPage{Webhook: hook, Error: message}
+	var err error
+	if page.Feeds, err = h.Store.GetFeeds(ctx, false); err != nil {
+		log.Println(err)
+	}
+	if page.Categories, err = h.Store.GetCategories(ctx); err != nil {
+		log.Println(err)
+	}
+	page.Deliveries, err = h.Store.FindDeliveries(ctx, store.DeliveryQuery{WebhookID: hook.ID, Limit: shownDeliveries})
+	if err != nil {
+		log.Println(err)
+	}
+	return page
+}
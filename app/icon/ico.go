@@ -0,0 +1,169 @@
+package icon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// The standard library cannot read .ico files, which is what most sites
+// serve as /favicon.ico. Entries are either embedded PNGs or BMPs without
+// the file header; only 24 and 32 bit BMPs are supported.
+
+func init() {
+	image.RegisterFormat("ico", "\x00\x00\x01\x00", decodeICO, decodeICOConfig)
+}
+
+type icoEntry struct {
+	Width, Height uint8
+	Colors        uint8
+	Reserved      uint8
+	Planes        uint16
+	BitCount      uint16
+	Size          uint32
+	Offset        uint32
+}
+
+func largestEntry(data []byte) (icoEntry, error) {
+	r := bytes.NewReader(data)
+	var header struct{ Reserved, Type, Count uint16 }
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return icoEntry{}, err
+	}
+	if header.Count == 0 {
+		return icoEntry{}, errors.New("ico: no images")
+	}
+	var best icoEntry
+	bestSize := -1
+	for i := 0; i < int(header.Count); i++ {
+		var e icoEntry
+		if err := binary.Read(r, binary.LittleEndian, &e); err != nil {
+			return icoEntry{}, err
+		}
+		// A width of zero means 256 pixels.
+		w := int(e.Width)
+		if w == 0 {
+			w = 256
+		}
+		if w > bestSize {
+			best, bestSize = e, w
+		}
+	}
+	if int(best.Offset)+int(best.Size) > len(data) {
+		return icoEntry{}, errors.New("ico: truncated image")
+	}
+	return best, nil
+}
+
+func decodeICO(r io.Reader) (image.Image, error) {
+	entry, err := readEntry(r)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(entry, []byte("\x89PNG")) {
+		config, err := png.DecodeConfig(bytes.NewReader(entry))
+		if err != nil {
+			return nil, err
+		}
+		if config.Width > maxDimension || config.Height > maxDimension {
+			return nil, errors.New("ico: image too large")
+		}
+		return png.Decode(bytes.NewReader(entry))
+	}
+	return decodeDIB(entry)
+}
+
+// decodeICOConfig reads the size of the largest image without decoding it.
+func decodeICOConfig(r io.Reader) (image.Config, error) {
+	entry, err := readEntry(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	if bytes.HasPrefix(entry, []byte("\x89PNG")) {
+		return png.DecodeConfig(bytes.NewReader(entry))
+	}
+	h, err := dibHeader(entry)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: color.NRGBAModel, Width: h.width, Height: h.height}, nil
+}
+
+// readEntry returns the bytes of the largest image in an .ico file.
+func readEntry(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	e, err := largestEntry(data)
+	if err != nil {
+		return nil, err
+	}
+	return data[int(e.Offset) : int(e.Offset)+int(e.Size)], nil
+}
+
+type dib struct {
+	headerSize, width, height, bitCount int
+}
+
+// dibHeader reads and checks the header of a BMP entry, whose values come
+// straight from the file.
+func dibHeader(data []byte) (dib, error) {
+	if len(data) < 40 {
+		return dib{}, errors.New("ico: short bitmap header")
+	}
+	h := dib{
+		headerSize: int(binary.LittleEndian.Uint32(data[0:])),
+		width:      int(int32(binary.LittleEndian.Uint32(data[4:]))),
+		// The height covers both the colour and the AND mask.
+		height:   int(int32(binary.LittleEndian.Uint32(data[8:]))) / 2,
+		bitCount: int(binary.LittleEndian.Uint16(data[14:])),
+	}
+	if h.headerSize < 40 || h.headerSize > len(data) {
+		return dib{}, errors.New("ico: invalid bitmap header size")
+	}
+	if h.width <= 0 || h.height <= 0 || h.width > 256 || h.height > 256 {
+		return dib{}, errors.New("ico: invalid bitmap size")
+	}
+	if h.bitCount != 24 && h.bitCount != 32 {
+		return dib{}, errors.New("ico: unsupported bit count")
+	}
+	return h, nil
+}
+
+func decodeDIB(data []byte) (image.Image, error) {
+	h, err := dibHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	width, height, bitCount := h.width, h.height, h.bitCount
+	bytesPerPixel := bitCount / 8
+	stride := (width*bytesPerPixel + 3) &^ 3
+	pixels := data[h.headerSize:]
+	if len(pixels) < stride*height {
+		return nil, errors.New("ico: truncated bitmap")
+	}
+	maskStride := ((width + 31) / 32) * 4
+	mask := pixels[stride*height:]
+	hasMask := bitCount == 24 && len(mask) >= maskStride*height
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		// Rows are stored bottom-up.
+		row := pixels[(height-1-y)*stride:]
+		for x := 0; x < width; x++ {
+			p := row[x*bytesPerPixel:]
+			c := color.NRGBA{R: p[2], G: p[1], B: p[0], A: 255}
+			if bitCount == 32 {
+				c.A = p[3]
+			} else if hasMask && mask[(height-1-y)*maskStride+x/8]&(0x80>>(x%8)) != 0 {
+				c.A = 0
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img, nil
+}
@@ -0,0 +1,56 @@
+package icon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// ico wraps a bitmap entry in an .ico file with one image.
+func ico(entry []byte) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, struct{ Reserved, Type, Count uint16 }{0, 1, 1})
+	binary.Write(&b, binary.LittleEndian, icoEntry{Width: 1, Height: 1, Planes: 1, BitCount: 32, Size: uint32(len(entry)), Offset: 22})
+	b.Write(entry)
+	return b.Bytes()
+}
+
+func dibEntry(headerSize uint32, width, height int32, pixels []byte) []byte {
+	header := make([]byte, 40)
+	binary.LittleEndian.PutUint32(header[0:], headerSize)
+	binary.LittleEndian.PutUint32(header[4:], uint32(width))
+	binary.LittleEndian.PutUint32(header[8:], uint32(height*2))
+	binary.LittleEndian.PutUint16(header[12:], 1)
+	binary.LittleEndian.PutUint16(header[14:], 32)
+	return append(header, pixels...)
+}
+
+func TestDecodeICO(t *testing.T) {
+	img, format, err := image.Decode(bytes.NewReader(ico(dibEntry(40, 1, 1, []byte{1, 2, 3, 4}))))
+	if err != nil || format != "ico" {
+		t.Fatal(format, err)
+	}
+	if c := img.(*image.NRGBA).NRGBAAt(0, 0); c != (color.NRGBA{R: 3, G: 2, B: 1, A: 4}) {
+		t.Error("wrong pixel", c)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(ico(dibEntry(40, 16, 16, nil))))
+	if err != nil || config.Width != 16 || config.Height != 16 {
+		t.Error(config, err)
+	}
+}
+
+func TestDecodeICORejectsCraftedBitmaps(t *testing.T) {
+	for name, entry := range map[string][]byte{
+		"header size past the data": dibEntry(100000, 1, 1, []byte{1, 2, 3, 4}),
+		"header size too small":     dibEntry(4, 1, 1, []byte{1, 2, 3, 4}),
+		"too few pixels":            dibEntry(40, 256, 256, []byte{1, 2, 3, 4}),
+		"too large":                 dibEntry(40, 100000, 1, nil),
+		"short header":              {40, 0, 0, 0},
+	} {
+		if _, _, err := image.Decode(bytes.NewReader(ico(entry))); err == nil {
+			t.Error(name, "decoded")
+		}
+	}
+}
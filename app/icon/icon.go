@@ -0,0 +1,165 @@
+package icon
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/jelinden/rssfetcher/app/domain"
+)
+
+const (
+	size        = 64
+	maxAge      = 7 * 24 * time.Hour
+	maxIconSize = 1 << 20
+	// maxDimension keeps small files claiming huge images from being
+	// decoded.
+	maxDimension = 1024
+)
+
+// Dir is the directory the normalized icons are stored in.
+var Dir = "icons"
+
+var client = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		Dial: (&net.Dialer{
+			Timeout: 5 * time.Second,
+		}).Dial,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 5 * time.Second,
+	},
+}
+
+// Path returns the file the icon of the feed with the given id is stored in.
+func Path(feedID string) string {
+	return filepath.Join(Dir, filepath.Base(feedID)+".png")
+}
+
+// RefreshAll fetches the icons of feeds that have none or an old one.
+func RefreshAll(feeds []domain.Feed) {
+	for _, feed := range feeds {
+		info, err := os.Stat(Path(feed.ID.Hex()))
+		if err == nil && time.Since(info.ModTime()) < maxAge {
+			continue
+		}
+		if err := Refresh(feed); err != nil {
+			log.Println("icon", feed.Name, err)
+		}
+	}
+}
+
+// Refresh finds the icon of a feed's site, falling back to the channel image,
+// and stores it as a PNG of a fixed size.
+func Refresh(feed domain.Feed) error {
+	for _, candidate := range candidates(feed) {
+		img, err := download(candidate)
+		if err != nil {
+			continue
+		}
+		return save(feed.ID.Hex(), resize(img, size))
+	}
+	return errors.New("no icon found")
+}
+
+func candidates(feed domain.Feed) []string {
+	var urls []string
+	if feed.SiteURL != "" {
+		urls = append(urls, pageIcons(feed.SiteURL)...)
+		if base, err := url.Parse(feed.SiteURL); err == nil && base.Host != "" {
+			urls = append(urls, base.ResolveReference(&url.URL{Path: "/favicon.ico"}).String())
+		}
+	}
+	if feed.Publisher != nil && feed.Publisher.Image != nil && feed.Publisher.Image.Url != "" {
+		urls = append(urls, feed.Publisher.Image.Url)
+	}
+	return urls
+}
+
+// pageIcons lists the icons linked from the site's page, apple-touch-icons
+// first since they are the largest.
+func pageIcons(siteURL string) []string {
+	resp, err := client.Get(siteURL)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	doc, err := goquery.NewDocumentFromReader(io.LimitReader(resp.Body, maxIconSize))
+	if err != nil {
+		return nil
+	}
+	base := resp.Request.URL
+	var touch, icons []string
+	doc.Find("link[rel][href]").Each(func(_ int, s *goquery.Selection) {
+		rel := strings.ToLower(s.AttrOr("rel", ""))
+		ref, err := url.Parse(strings.TrimSpace(s.AttrOr("href", "")))
+		if err != nil {
+			return
+		}
+		href := base.ResolveReference(ref).String()
+		switch {
+		case strings.Contains(rel, "apple-touch-icon"):
+			touch = append(touch, href)
+		case strings.Contains(rel, "icon"):
+			icons = append(icons, href)
+		}
+	})
+	return append(touch, icons...)
+}
+
+func download(link string) (image.Image, error) {
+	resp, err := client.Get(link)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxIconSize))
+	if err != nil {
+		return nil, err
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width > maxDimension || config.Height > maxDimension {
+		return nil, fmt.Errorf("image too large, %dx%d", config.Width, config.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+func save(feedID string, img image.Image) error {
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(Dir, feedID+"-*.png")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := png.Encode(tmp, img); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), Path(feedID))
+}
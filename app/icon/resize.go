@@ -0,0 +1,54 @@
+package icon
+
+import (
+	"image"
+	"image/color"
+)
+
+// resize scales img to fit a square of n pixels, keeping its aspect ratio and
+// centering it on a transparent background. Each target pixel is the average
+// of the source pixels it covers.
+func resize(img image.Image, n int) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, n, n))
+	b := img.Bounds()
+	if b.Empty() {
+		return dst
+	}
+	w, h := n, n
+	if b.Dx() > b.Dy() {
+		h = max(1, n*b.Dy()/b.Dx())
+	} else if b.Dy() > b.Dx() {
+		w = max(1, n*b.Dx()/b.Dy())
+	}
+	offX, offY := (n-w)/2, (n-h)/2
+	for y := 0; y < h; y++ {
+		y0 := b.Min.Y + y*b.Dy()/h
+		y1 := max(y0+1, b.Min.Y+(y+1)*b.Dy()/h)
+		for x := 0; x < w; x++ {
+			x0 := b.Min.X + x*b.Dx()/w
+			x1 := max(x0+1, b.Min.X+(x+1)*b.Dx()/w)
+			var r, g, bl, a, count uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := color.NRGBA64Model.Convert(img.At(sx, sy)).(color.NRGBA64)
+					// Weight by alpha so transparent pixels do not darken the edges.
+					r += uint64(c.R) * uint64(c.A)
+					g += uint64(c.G) * uint64(c.A)
+					bl += uint64(c.B) * uint64(c.A)
+					a += uint64(c.A)
+					count++
+				}
+			}
+			if a == 0 {
+				continue
+			}
+			dst.SetNRGBA(offX+x, offY+y, color.NRGBA{
+				R: uint8(r / a >> 8),
+				G: uint8(g / a >> 8),
+				B: uint8(bl / a >> 8),
+				A: uint8(a / count >> 8),
+			})
+		}
+	}
+	return dst
+}
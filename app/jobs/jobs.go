@@ -0,0 +1,110 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const keep = 20
+
+const (
+	Running = "running"
+	Done    = "done"
+	Failed  = "failed"
+)
+
+// Job is a snapshot of a background job's progress.
+type Job struct {
+	ID       int       `json:"id"`
+	Name     string    `json:"name"`
+	Total    int       `json:"total"`
+	Done     int       `json:"done"`
+	State    string    `json:"state"`
+	Error    string    `json:"error,omitempty"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+}
+
+func (j Job) Percent() int {
+	if j.Total <= 0 {
+		if j.State == Done {
+			return 100
+		}
+		return 0
+	}
+	return min(100, j.Done*100/j.Total)
+}
+
+// Runner runs jobs in the background and remembers the latest ones.
+type Runner struct {
+	mu     sync.Mutex
+	nextID int
+	jobs   []*Job
+}
+
+func NewRunner() *Runner {
+	return &Runner{}
+}
+
+// Start runs fn in a goroutine. fn reports the number of units done with
+// progress, total is how many there are in all.
+func (r *Runner) Start(name string, total int, fn func(ctx context.Context, progress func(n int)) error) Job {
+	r.mu.Lock()
+	r.nextID++
+	job := &Job{ID: r.nextID, Name: name, Total: total, State: Running, Started: time.Now()}
+	r.jobs = append(r.jobs, job)
+	if len(r.jobs) > keep {
+		r.jobs = r.jobs[len(r.jobs)-keep:]
+	}
+	snapshot := *job
+	r.mu.Unlock()
+
+	go func() {
+		log.Println("job", job.ID, name, "started")
+		err := fn(context.Background(), func(n int) {
+			r.mu.Lock()
+			job.Done += n
+			r.mu.Unlock()
+		})
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		job.Finished = time.Now()
+		if err != nil {
+			job.State = Failed
+			job.Error = err.Error()
+			log.Println("job", job.ID, name, "failed", err)
+			return
+		}
+		job.State = Done
+		if job.Done > job.Total {
+			job.Total = job.Done
+		}
+		log.Println("job", job.ID, name, "done", job.Done)
+	}()
+	return snapshot
+}
+
+// List returns the latest jobs, newest first.
+func (r *Runner) List() []Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]Job, 0, len(r.jobs))
+	for i := len(r.jobs) - 1; i >= 0; i-- {
+		list = append(list, *r.jobs[i])
+	}
+	return list
+}
+
+// Get returns a job still remembered by the runner.
+func (r *Runner) Get(id int) (Job, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, job := range r.jobs {
+		if job.ID == id {
+			return *job, true
+		}
+	}
+	return Job{}, false
+}
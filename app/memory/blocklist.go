@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/domain"
+	"github.com/jelinden/rssfetcher/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (m *Repository) HideItems(ctx context.Context, ids []primitive.ObjectID, rule domain.BlockRule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.items {
+		if slices.Contains(ids, m.items[i].ID) {
+			m.items[i].Hidden = true
+			m.items[i].HiddenBy = rule.ID
+			m.items[i].HiddenReason = rule.Reason
+		}
+	}
+	return nil
+}
+
+func (m *Repository) UnhideItems(ctx context.Context, ruleID primitive.ObjectID) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for i := range m.items {
+		if m.items[i].Hidden && m.items[i].HiddenBy == ruleID {
+			m.items[i].Hidden = false
+			m.items[i].HiddenBy = primitive.NilObjectID
+			m.items[i].HiddenReason = ""
+			m.items[i].Updated = time.Now()
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *Repository) GetBlockRules(ctx context.Context) ([]domain.BlockRule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]domain.BlockRule{}, m.blockRules...), nil
+}
+
+func (m *Repository) SaveBlockRule(ctx context.Context, rule domain.BlockRule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.blockRules {
+		if m.blockRules[i].ID == rule.ID {
+			m.blockRules[i] = rule
+			return nil
+		}
+	}
+	m.blockRules = append(m.blockRules, rule)
+	return nil
+}
+
+func (m *Repository) DeleteBlockRule(ctx context.Context, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := len(m.blockRules)
+	m.blockRules = slices.DeleteFunc(m.blockRules, func(rule domain.BlockRule) bool {
+		return rule.ID == id
+	})
+	if len(m.blockRules) == n {
+		return store.ErrNotFound
+	}
+	return nil
+}
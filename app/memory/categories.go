@@ -0,0 +1,167 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (m *Repository) GetCategoryByID(ctx context.Context, id primitive.ObjectID) (rss.Category, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, category := range m.categories {
+		if category.ID == id {
+			return category, nil
+		}
+	}
+	return rss.Category{}, store.ErrNotFound
+}
+
+func (m *Repository) UpdateCategory(ctx context.Context, category rss.Category) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	found := -1
+	for i, c := range m.categories {
+		if c.Name == category.Name && c.ID != category.ID {
+			return store.ErrDuplicate
+		}
+		if c.ID == category.ID {
+			found = i
+		}
+	}
+	if found < 0 {
+		return store.ErrNotFound
+	}
+	m.categories[found] = category
+	return nil
+}
+
+func (m *Repository) CountCategoryRefs(ctx context.Context, id primitive.ObjectID) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n := 0
+	for _, feed := range m.feeds {
+		if feed.Category.ID == id {
+			n++
+		}
+	}
+	for _, item := range m.items {
+		if item.Category.ID == id {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *Repository) ReassignCategory(ctx context.Context, from primitive.ObjectID, to rss.Category, limit int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for i := range m.feeds {
+		if n < limit && m.feeds[i].Category.ID == from && m.feeds[i].Category != to {
+			m.feeds[i].Category = to
+			n++
+		}
+	}
+	for i := range m.items {
+		if n < limit && m.items[i].Category.ID == from && m.items[i].Category != to {
+			m.items[i].Category = to
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *Repository) DeleteCategory(ctx context.Context, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, category := range m.categories {
+		if category.ID == id {
+			m.categories = append(m.categories[:i], m.categories[i+1:]...)
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
+
+func (m *Repository) GetSubCategoryByID(ctx context.Context, id primitive.ObjectID) (rss.SubCategory, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, subCategory := range m.subCategories {
+		if subCategory.ID == id {
+			return subCategory, nil
+		}
+	}
+	return rss.SubCategory{}, store.ErrNotFound
+}
+
+func (m *Repository) UpdateSubCategory(ctx context.Context, subCategory rss.SubCategory) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	found := -1
+	for i, s := range m.subCategories {
+		if s.SubCategory == subCategory.SubCategory && s.ID != subCategory.ID {
+			return store.ErrDuplicate
+		}
+		if s.ID == subCategory.ID {
+			found = i
+		}
+	}
+	if found < 0 {
+		return store.ErrNotFound
+	}
+	m.subCategories[found] = subCategory
+	return nil
+}
+
+func (m *Repository) CountSubCategoryRefs(ctx context.Context, id primitive.ObjectID) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n := 0
+	for _, feed := range m.feeds {
+		if feed.SubCategory != nil && feed.SubCategory.ID == id {
+			n++
+		}
+	}
+	for _, item := range m.items {
+		if item.SubCategory != nil && item.SubCategory.ID == id {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *Repository) ReassignSubCategory(ctx context.Context, from primitive.ObjectID, to rss.SubCategory, limit int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for i := range m.feeds {
+		if s := m.feeds[i].SubCategory; n < limit && s != nil && s.ID == from && *s != to {
+			subCategory := to
+			m.feeds[i].SubCategory = &subCategory
+			n++
+		}
+	}
+	for i := range m.items {
+		if s := m.items[i].SubCategory; n < limit && s != nil && s.ID == from && *s != to {
+			subCategory := to
+			m.items[i].SubCategory = &subCategory
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *Repository) DeleteSubCategory(ctx context.Context, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, subCategory := range m.subCategories {
+		if subCategory.ID == id {
+			m.subCategories = append(m.subCategories[:i], m.subCategories[i+1:]...)
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type clickKey struct {
+	itemID primitive.ObjectID
+	hour   int64
+}
+
+func (m *Repository) AddClick(ctx context.Context, itemID primitive.ObjectID, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.items {
+		if m.items[i].ID == itemID {
+			m.items[i].Clicks++
+			if m.clickCounts == nil {
+				m.clickCounts = make(map[clickKey]int)
+			}
+			m.clickCounts[clickKey{itemID, at.Truncate(time.Hour).Unix()}]++
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
+
+func (m *Repository) ClickCounts(ctx context.Context, since time.Time) ([]store.ClickCount, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	from := since.Truncate(time.Hour).Unix()
+	counts := []store.ClickCount{}
+	for key, clicks := range m.clickCounts {
+		if key.hour >= from {
+			counts = append(counts, store.ClickCount{ItemID: key.itemID, Hour: time.Unix(key.hour, 0).UTC(), Clicks: clicks})
+		}
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i].Hour.Before(counts[j].Hour)
+	})
+	return counts, nil
+}
+
+func (m *Repository) DeleteClickCounts(ctx context.Context, before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key := range m.clickCounts {
+		if key.hour < before.Unix() {
+			delete(m.clickCounts, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,376 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/domain"
+	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Repository is a store.Store kept in memory, meant for tests and trying
+// things out without a database.
+type Repository struct {
+	mu            sync.RWMutex
+	feeds         []domain.Feed
+	categories    []rss.Category
+	subCategories []rss.SubCategory
+	items         []rss.Item
+	archive       map[primitive.ObjectID]rss.Item
+	clickCounts   map[clickKey]int
+	webhooks      []domain.Webhook
+	deliveries    []domain.Delivery
+	blockRules    []domain.BlockRule
+}
+
+var _ store.Store = (*Repository)(nil)
+
+func New() *Repository {
+	return &Repository{}
+}
+
+func (m *Repository) Close(ctx context.Context) error {
+	return nil
+}
+
+func (m *Repository) GetFeeds(ctx context.Context, activeOnly bool) ([]domain.Feed, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	feedList := []domain.Feed{}
+	for _, feed := range m.feeds {
+		if activeOnly && feed.Removed {
+			continue
+		}
+		feedList = append(feedList, copyFeed(feed))
+	}
+	return feedList, nil
+}
+
+func (m *Repository) GetFeed(ctx context.Context, feedID string) (*domain.Feed, error) {
+	if feedID == "" {
+		return nil, errors.New("feedID was empty")
+	}
+	id, err := primitive.ObjectIDFromHex(feedID)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, feed := range m.feeds {
+		if feed.ID == id {
+			feed = copyFeed(feed)
+			return &feed, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (m *Repository) SaveFeed(ctx context.Context, feed domain.Feed) error {
+	if feed.SubCategory != nil && feed.SubCategory.ID.IsZero() {
+		feed.SubCategory = nil
+	}
+	feed = copyFeed(feed)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.feeds {
+		if m.feeds[i].ID == feed.ID {
+			m.feeds[i] = feed
+			return nil
+		}
+	}
+	m.feeds = append(m.feeds, feed)
+	return nil
+}
+
+func (m *Repository) SavePublisher(ctx context.Context, feedID primitive.ObjectID, publisher domain.Publisher) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.feeds {
+		if m.feeds[i].ID == feedID {
+			m.feeds[i].Publisher = &publisher
+			return nil
+		}
+	}
+	return nil
+}
+
+func (m *Repository) GetCategories(ctx context.Context) ([]rss.Category, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]rss.Category{}, m.categories...), nil
+}
+
+func (m *Repository) GetCategory(ctx context.Context, name string) (rss.Category, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, category := range m.categories {
+		if category.Name == name {
+			return category, nil
+		}
+	}
+	return rss.Category{}, store.ErrNotFound
+}
+
+func (m *Repository) SaveCategory(ctx context.Context, category rss.Category) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.categories {
+		if c.Name == category.Name {
+			return nil
+		}
+	}
+	m.categories = append(m.categories, category)
+	return nil
+}
+
+func (m *Repository) GetSubCategories(ctx context.Context) ([]rss.SubCategory, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]rss.SubCategory{}, m.subCategories...), nil
+}
+
+func (m *Repository) GetSubCategory(ctx context.Context, name string) (rss.SubCategory, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, subCategory := range m.subCategories {
+		if subCategory.SubCategory == name {
+			return subCategory, nil
+		}
+	}
+	return rss.SubCategory{}, store.ErrNotFound
+}
+
+func (m *Repository) SaveSubCategory(ctx context.Context, subCategory rss.SubCategory) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.subCategories {
+		if s.SubCategory == subCategory.SubCategory {
+			return nil
+		}
+	}
+	m.subCategories = append(m.subCategories, subCategory)
+	return nil
+}
+
+func (m *Repository) GetItem(ctx context.Context, itemID string) (*rss.Item, error) {
+	id, err := primitive.ObjectIDFromHex(itemID)
+	if err != nil {
+		return nil, store.ErrNotFound
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, item := range m.items {
+		if item.ID == id {
+			item = copyItem(item)
+			return &item, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (m *Repository) SaveItems(ctx context.Context, items []rss.Item) ([]store.ItemChange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	changes := []store.ItemChange{}
+	for _, item := range items {
+		item = copyItem(item)
+		if i := m.findItem(item); i >= 0 {
+			stored := m.items[i]
+			item = store.Merge(stored, item)
+			m.items[i] = item
+			if store.Changed(stored, item) {
+				changes = append(changes, store.ItemChange{Item: copyItem(item), Updated: true})
+			}
+		} else {
+			item.ID = primitive.NewObjectID()
+			item.Updated = time.Now()
+			m.items = append(m.items, item)
+			changes = append(changes, store.ItemChange{Item: copyItem(item)})
+		}
+	}
+	return changes, nil
+}
+
+func (m *Repository) FindItems(ctx context.Context, q store.ItemQuery) ([]rss.Item, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	items := []rss.Item{}
+	for _, item := range m.items {
+		if matches(item, q) {
+			items = append(items, copyItem(item))
+		}
+	}
+	before := func(a, b rss.Item) bool {
+		if q.ByClicks && a.Clicks != b.Clicks {
+			return a.Clicks > b.Clicks
+		}
+		if q.Oldest && !q.ByClicks {
+			a, b = b, a
+		}
+		if !a.Date.Equal(b.Date) {
+			return a.Date.After(b.Date)
+		}
+		return a.ID.Hex() > b.ID.Hex()
+	}
+	if c := q.Cursor; c != nil {
+		at := rss.Item{ID: c.ID, Date: c.Date, Clicks: c.Clicks}
+		items = slices.DeleteFunc(items, func(item rss.Item) bool {
+			return !before(at, item)
+		})
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return before(items[i], items[j])
+	})
+	if q.Skip >= len(items) {
+		return []rss.Item{}, nil
+	}
+	items = items[q.Skip:]
+	if q.Limit > 0 && q.Limit < len(items) {
+		items = items[:q.Limit]
+	}
+	return items, nil
+}
+
+func matches(item rss.Item, q store.ItemQuery) bool {
+	if item.Hidden && !q.WithHidden {
+		return false
+	}
+	if q.Category != "" && item.Category.EnName != q.Category && item.Category.Name != q.Category {
+		return false
+	}
+	if q.SubCategory != "" && (item.SubCategory == nil ||
+		item.SubCategory.EnName != q.SubCategory && item.SubCategory.SubCategory != q.SubCategory) {
+		return false
+	}
+	if slices.Contains(q.NotCategories, item.Category.EnName) || slices.Contains(q.NotCategories, item.Category.Name) {
+		return false
+	}
+	if len(q.IDs) > 0 && !slices.Contains(q.IDs, item.ID) {
+		return false
+	}
+	if q.Source != "" && item.Source != q.Source {
+		return false
+	}
+	if !q.FeedID.IsZero() && item.FeedID != q.FeedID {
+		return false
+	}
+	if q.Language != "" && item.Language != q.Language {
+		return false
+	}
+	if !q.ClusterID.IsZero() && item.ClusterID != q.ClusterID {
+		return false
+	}
+	if q.Representatives && !item.ClusterID.IsZero() && !item.Representative {
+		return false
+	}
+	if !q.After.IsZero() && item.Date.Before(q.After) {
+		return false
+	}
+	if !q.Before.IsZero() && !item.Date.Before(q.Before) {
+		return false
+	}
+	if q.ClicksBelow > 0 && item.Clicks >= q.ClicksBelow {
+		return false
+	}
+	if q.ClicksAtLeast > 0 && item.Clicks < q.ClicksAtLeast {
+		return false
+	}
+	if q.Text != "" {
+		text := strings.ToLower(q.Text)
+		if !strings.Contains(strings.ToLower(item.Title), text) && !strings.Contains(strings.ToLower(item.Content), text) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Repository) DeleteItems(ctx context.Context, ids []primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items = slices.DeleteFunc(m.items, func(item rss.Item) bool {
+		return slices.Contains(ids, item.ID)
+	})
+	return nil
+}
+
+func (m *Repository) ArchiveItems(ctx context.Context, items []rss.Item) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.archive == nil {
+		m.archive = make(map[primitive.ObjectID]rss.Item)
+	}
+	for _, item := range items {
+		m.archive[item.ID] = copyItem(item)
+	}
+	return nil
+}
+
+func (m *Repository) SetClusters(ctx context.Context, updates []store.ClusterUpdate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range updates {
+		for i := range m.items {
+			if m.items[i].ID == u.ItemID {
+				m.items[i].ClusterID = u.ClusterID
+				m.items[i].Representative = u.Representative && !u.ClusterID.IsZero()
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Repository) BackfillFeedID(ctx context.Context, source string, feedID primitive.ObjectID) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for i := range m.items {
+		if m.items[i].Source == source && m.items[i].FeedID.IsZero() {
+			m.items[i].FeedID = feedID
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *Repository) findItem(item rss.Item) int {
+	for i, stored := range m.items {
+		if stored.GUID == item.GUID || (item.CanonicalURL != "" && stored.CanonicalURL == item.CanonicalURL) {
+			return i
+		}
+	}
+	return -1
+}
+
+// The copies keep callers from changing stored values through pointers.
+
+func copyFeed(feed domain.Feed) domain.Feed {
+	if feed.SubCategory != nil {
+		subCategory := *feed.SubCategory
+		feed.SubCategory = &subCategory
+	}
+	if feed.Publisher != nil {
+		publisher := *feed.Publisher
+		if publisher.Image != nil {
+			image := *publisher.Image
+			publisher.Image = &image
+		}
+		feed.Publisher = &publisher
+	}
+	return feed
+}
+
+func copyItem(item rss.Item) rss.Item {
+	if item.SubCategory != nil {
+		subCategory := *item.SubCategory
+		item.SubCategory = &subCategory
+	}
+	item.Tags = slices.Clone(item.Tags)
+	return item
+}
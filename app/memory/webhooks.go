@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/domain"
+	"github.com/jelinden/rssfetcher/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (m *Repository) GetWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]domain.Webhook{}, m.webhooks...), nil
+}
+
+func (m *Repository) GetWebhook(ctx context.Context, id primitive.ObjectID) (*domain.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, webhook := range m.webhooks {
+		if webhook.ID == id {
+			return &webhook, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (m *Repository) SaveWebhook(ctx context.Context, webhook domain.Webhook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.webhooks {
+		if m.webhooks[i].ID == webhook.ID {
+			m.webhooks[i] = webhook
+			return nil
+		}
+	}
+	m.webhooks = append(m.webhooks, webhook)
+	return nil
+}
+
+func (m *Repository) DeleteWebhook(ctx context.Context, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := len(m.webhooks)
+	m.webhooks = slices.DeleteFunc(m.webhooks, func(webhook domain.Webhook) bool {
+		return webhook.ID == id
+	})
+	if len(m.webhooks) == n {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (m *Repository) SaveDelivery(ctx context.Context, delivery domain.Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.deliveries {
+		if m.deliveries[i].ID == delivery.ID {
+			m.deliveries[i] = delivery
+			return nil
+		}
+	}
+	m.deliveries = append(m.deliveries, delivery)
+	return nil
+}
+
+func (m *Repository) GetDelivery(ctx context.Context, id primitive.ObjectID) (*domain.Delivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, delivery := range m.deliveries {
+		if delivery.ID == id {
+			return &delivery, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (m *Repository) FindDeliveries(ctx context.Context, q store.DeliveryQuery) ([]domain.Delivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	deliveries := []domain.Delivery{}
+	for _, delivery := range m.deliveries {
+		if (q.WebhookID.IsZero() || delivery.WebhookID == q.WebhookID) && (q.Status == "" || delivery.Status == q.Status) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		return deliveries[i].Created.After(deliveries[j].Created)
+	})
+	if q.Limit > 0 && q.Limit < len(deliveries) {
+		deliveries = deliveries[:q.Limit]
+	}
+	return deliveries, nil
+}
+
+func (m *Repository) DeleteDeliveries(ctx context.Context, before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries = slices.DeleteFunc(m.deliveries, func(delivery domain.Delivery) bool {
+		return delivery.Created.Before(before)
+	})
+	return nil
+}
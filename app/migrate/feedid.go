@@ -0,0 +1,34 @@
+package migrate
+
+import (
+	"context"
+	"log"
+
+	"github.com/jelinden/rssfetcher/app/domain"
+	"github.com/jelinden/rssfetcher/app/store"
+)
+
+// backfillFeedIDs sets the feed ID of items stored before items carried
+// one. Names shared by several feeds are ambiguous and left alone.
+func backfillFeedIDs(ctx context.Context, s store.Store) error {
+	feeds, err := s.GetFeeds(ctx, false)
+	if err != nil {
+		return err
+	}
+	byName := make(map[string][]domain.Feed)
+	for _, feed := range feeds {
+		byName[feed.Name] = append(byName[feed.Name], feed)
+	}
+	for name, named := range byName {
+		if len(named) > 1 {
+			log.Println("skipping", len(named), "feeds named", name)
+			continue
+		}
+		n, err := s.BackfillFeedID(ctx, name, named[0].ID)
+		if err != nil {
+			return err
+		}
+		log.Println("linked", n, "items to feed", name, named[0].ID.Hex())
+	}
+	return nil
+}
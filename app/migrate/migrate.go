@@ -0,0 +1,44 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/jelinden/rssfetcher/app/store"
+)
+
+// Migration is a one-off data fix run with the -migrate flag. Migrations
+// must be safe to run more than once.
+type Migration struct {
+	Description string
+	Run         func(ctx context.Context, s store.Store) error
+}
+
+var migrations = map[string]Migration{
+	"item-feed-ids": {
+		Description: "link existing news items to their feed by matching rssSource to feed names",
+		Run:         backfillFeedIDs,
+	},
+	"subcategory-parents": {
+		Description: "set the parent category of subcategories from the feeds using them",
+		Run:         assignParents,
+	},
+}
+
+func Run(ctx context.Context, s store.Store, name string) error {
+	m, ok := migrations[name]
+	if !ok {
+		return fmt.Errorf("unknown migration %q, known: %v", name, Names())
+	}
+	return m.Run(ctx, s)
+}
+
+func Names() []string {
+	names := make([]string, 0, len(migrations))
+	for name := range migrations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,62 @@
+package migrate
+
+import (
+	"context"
+	"log"
+
+	"github.com/jelinden/rssfetcher/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// assignParents gives each subcategory without a parent the category of
+// the feeds using it, and updates the copies embedded in feeds and items.
+// Subcategories that are unused or used under several categories are left
+// for editing by hand.
+func assignParents(ctx context.Context, s store.Store) error {
+	subCategories, err := s.GetSubCategories(ctx)
+	if err != nil {
+		return err
+	}
+	feeds, err := s.GetFeeds(ctx, false)
+	if err != nil {
+		return err
+	}
+	parents := make(map[primitive.ObjectID]map[primitive.ObjectID]bool)
+	for _, feed := range feeds {
+		if feed.SubCategory == nil || feed.Category.ID.IsZero() {
+			continue
+		}
+		if parents[feed.SubCategory.ID] == nil {
+			parents[feed.SubCategory.ID] = make(map[primitive.ObjectID]bool)
+		}
+		parents[feed.SubCategory.ID][feed.Category.ID] = true
+	}
+	for _, subCategory := range subCategories {
+		if !subCategory.ParentID.IsZero() {
+			continue
+		}
+		if len(parents[subCategory.ID]) != 1 {
+			log.Println("skipping", subCategory.SubCategory, "used under", len(parents[subCategory.ID]), "categories")
+			continue
+		}
+		for parentID := range parents[subCategory.ID] {
+			subCategory.ParentID = parentID
+		}
+		if err := s.UpdateSubCategory(ctx, subCategory); err != nil {
+			return err
+		}
+		total := 0
+		for {
+			n, err := s.ReassignSubCategory(ctx, subCategory.ID, subCategory, 500)
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				break
+			}
+			total += n
+		}
+		log.Println("moved", subCategory.SubCategory, "under", subCategory.ParentID.Hex(), "updating", total, "feeds and items")
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (m *MongoRepository) HideItems(ctx context.Context, ids []primitive.ObjectID, rule domain.BlockRule) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := m.collection(m.Config.News).UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": ids}},
+		bson.D{{Key: "$set", Value: bson.M{"hidden": true, "hiddenBy": rule.ID, "hiddenReason": rule.Reason}}})
+	return err
+}
+
+func (m *MongoRepository) UnhideItems(ctx context.Context, ruleID primitive.ObjectID) (int, error) {
+	result, err := m.collection(m.Config.News).UpdateMany(ctx,
+		bson.M{"hidden": true, "hiddenBy": ruleID},
+		bson.D{
+			{Key: "$unset", Value: bson.M{"hidden": "", "hiddenBy": "", "hiddenReason": ""}},
+			{Key: "$set", Value: bson.M{"updated": time.Now()}},
+		})
+	if err != nil {
+		return 0, err
+	}
+	return int(result.ModifiedCount), nil
+}
+
+func (m *MongoRepository) GetBlockRules(ctx context.Context) ([]domain.BlockRule, error) {
+	cursor, err := m.collection(m.Config.Blocklist).Find(ctx, bson.M{},
+		options.Find().SetSort(bson.D{{Key: "created", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	rules := []domain.BlockRule{}
+	if err := cursor.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (m *MongoRepository) SaveBlockRule(ctx context.Context, rule domain.BlockRule) error {
+	_, err := m.collection(m.Config.Blocklist).ReplaceOne(ctx,
+		bson.M{"_id": rule.ID}, rule, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (m *MongoRepository) DeleteBlockRule(ctx context.Context, id primitive.ObjectID) error {
+	return m.deleteOne(ctx, m.Config.Blocklist, id)
+}
@@ -0,0 +1,171 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/store"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (m *MongoRepository) GetCategoryByID(ctx context.Context, id primitive.ObjectID) (rss.Category, error) {
+	category := rss.Category{}
+	err := m.collection(m.Config.Categories).FindOne(ctx, bson.M{"_id": id}).Decode(&category)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return category, store.ErrNotFound
+	}
+	return category, err
+}
+
+func (m *MongoRepository) UpdateCategory(ctx context.Context, category rss.Category) error {
+	c := m.collection(m.Config.Categories)
+	n, err := c.CountDocuments(ctx, bson.M{"categoryName": category.Name, "_id": bson.M{"$ne": category.ID}})
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return store.ErrDuplicate
+	}
+	result, err := c.ReplaceOne(ctx, bson.M{"_id": category.ID}, category)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (m *MongoRepository) CountCategoryRefs(ctx context.Context, id primitive.ObjectID) (int, error) {
+	return m.countRefs(ctx, bson.M{"category._id": id})
+}
+
+func (m *MongoRepository) ReassignCategory(ctx context.Context, from primitive.ObjectID, to rss.Category, limit int) (int, error) {
+	filter := bson.M{"category._id": from, "$or": []bson.M{
+		{"category._id": bson.M{"$ne": to.ID}},
+		{"category.categoryName": bson.M{"$ne": to.Name}},
+		{"category.enName": bson.M{"$ne": to.EnName}},
+		{"category.styleName": bson.M{"$ne": to.StyleName}},
+	}}
+	return m.reassign(ctx, filter, bson.M{"category": to}, limit)
+}
+
+func (m *MongoRepository) DeleteCategory(ctx context.Context, id primitive.ObjectID) error {
+	return m.deleteOne(ctx, m.Config.Categories, id)
+}
+
+func (m *MongoRepository) GetSubCategoryByID(ctx context.Context, id primitive.ObjectID) (rss.SubCategory, error) {
+	subCategory := rss.SubCategory{}
+	err := m.collection(m.Config.SubCategories).FindOne(ctx, bson.M{"_id": id}).Decode(&subCategory)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return subCategory, store.ErrNotFound
+	}
+	return subCategory, err
+}
+
+func (m *MongoRepository) UpdateSubCategory(ctx context.Context, subCategory rss.SubCategory) error {
+	c := m.collection(m.Config.SubCategories)
+	n, err := c.CountDocuments(ctx, bson.M{"subCategory": subCategory.SubCategory, "_id": bson.M{"$ne": subCategory.ID}})
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return store.ErrDuplicate
+	}
+	result, err := c.ReplaceOne(ctx, bson.M{"_id": subCategory.ID}, subCategory)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (m *MongoRepository) CountSubCategoryRefs(ctx context.Context, id primitive.ObjectID) (int, error) {
+	return m.countRefs(ctx, bson.M{"subCategory._id": id})
+}
+
+func (m *MongoRepository) ReassignSubCategory(ctx context.Context, from primitive.ObjectID, to rss.SubCategory, limit int) (int, error) {
+	// parentId is left out when zero, so $ne would match every copy.
+	parent := bson.M{"subCategory.parentId": bson.M{"$ne": to.ParentID}}
+	if to.ParentID.IsZero() {
+		parent = bson.M{"subCategory.parentId": bson.M{"$exists": true}}
+	}
+	filter := bson.M{"subCategory._id": from, "$or": []bson.M{
+		{"subCategory._id": bson.M{"$ne": to.ID}},
+		{"subCategory.subCategory": bson.M{"$ne": to.SubCategory}},
+		{"subCategory.enName": bson.M{"$ne": to.EnName}},
+		parent,
+	}}
+	return m.reassign(ctx, filter, bson.M{"subCategory": to}, limit)
+}
+
+func (m *MongoRepository) DeleteSubCategory(ctx context.Context, id primitive.ObjectID) error {
+	return m.deleteOne(ctx, m.Config.SubCategories, id)
+}
+
+func (m *MongoRepository) deleteOne(ctx context.Context, name string, id primitive.ObjectID) error {
+	result, err := m.collection(name).DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (m *MongoRepository) countRefs(ctx context.Context, filter bson.M) (int, error) {
+	total := 0
+	for _, name := range []string{m.Config.Feeds, m.Config.News} {
+		n, err := m.collection(name).CountDocuments(ctx, filter)
+		if err != nil {
+			return 0, err
+		}
+		total += int(n)
+	}
+	return total, nil
+}
+
+// reassign sets the update on at most limit documents matching filter,
+// feeds first, then items.
+func (m *MongoRepository) reassign(ctx context.Context, filter bson.M, set bson.M, limit int) (int, error) {
+	total := 0
+	for _, name := range []string{m.Config.Feeds, m.Config.News} {
+		if total >= limit {
+			break
+		}
+		c := m.collection(name)
+		cursor, err := c.Find(ctx, filter, options.Find().
+			SetProjection(bson.M{"_id": 1}).
+			SetLimit(int64(limit-total)))
+		if err != nil {
+			return total, err
+		}
+		var docs []struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.All(ctx, &docs); err != nil {
+			return total, err
+		}
+		if len(docs) == 0 {
+			continue
+		}
+		ids := make([]primitive.ObjectID, len(docs))
+		for i, doc := range docs {
+			ids[i] = doc.ID
+		}
+		result, err := c.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": ids}}, bson.D{{Key: "$set", Value: set}})
+		if err != nil {
+			return total, err
+		}
+		total += int(result.ModifiedCount)
+	}
+	return total, nil
+}
@@ -0,0 +1,48 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/store"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (m *MongoRepository) AddClick(ctx context.Context, itemID primitive.ObjectID, at time.Time) error {
+	result, err := m.collection(m.Config.News).UpdateOne(ctx,
+		bson.M{"_id": itemID}, bson.M{"$inc": bson.M{"clicks": 1}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return store.ErrNotFound
+	}
+	hour := at.UTC().Truncate(time.Hour)
+	_, err = m.collection(m.Config.Clicks).UpdateOne(ctx,
+		bson.M{"itemId": itemID, "hour": hour},
+		bson.M{"$inc": bson.M{"clicks": 1}},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+func (m *MongoRepository) ClickCounts(ctx context.Context, since time.Time) ([]store.ClickCount, error) {
+	cursor, err := m.collection(m.Config.Clicks).Find(ctx,
+		bson.M{"hour": bson.M{"$gte": since.UTC().Truncate(time.Hour)}},
+		options.Find().SetSort(bson.D{{Key: "hour", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	counts := []store.ClickCount{}
+	if err := cursor.All(ctx, &counts); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+func (m *MongoRepository) DeleteClickCounts(ctx context.Context, before time.Time) error {
+	_, err := m.collection(m.Config.Clicks).DeleteMany(ctx, bson.M{"hour": bson.M{"$lt": before}})
+	return err
+}
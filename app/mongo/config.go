@@ -0,0 +1,94 @@
+package mongo
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// Config names the database and collections the repository uses, so that
+// several environments can share one cluster.
+type Config struct {
+	Database      string `json:"database"`
+	Feeds         string `json:"feedCollection"`
+	News          string `json:"newsCollection"`
+	Categories    string `json:"categoryCollection"`
+	SubCategories string `json:"subCategoryCollection"`
+	Archive       string `json:"archiveCollection"`
+	// Clicks holds the hourly click counters of news items.
+	Clicks string `json:"clickCollection"`
+	// Webhooks holds the webhook subscriptions, Deliveries their delivery
+	// log.
+	Webhooks   string `json:"webhookCollection"`
+	Deliveries string `json:"deliveryCollection"`
+	// Blocklist holds the global block rules.
+	Blocklist string `json:"blocklistCollection"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Database:      "news",
+		Feeds:         "feedcollection",
+		News:          "newscollection",
+		Categories:    "categorycollection",
+		SubCategories: "subcategorycollection",
+		Archive:       "newsarchive",
+		Clicks:        "clickcollection",
+		Webhooks:      "webhookcollection",
+		Deliveries:    "deliverycollection",
+		Blocklist:     "blocklistcollection",
+	}
+}
+
+// LoadConfig reads a JSON config file on top of the defaults. An empty path
+// returns the defaults.
+func LoadConfig(path string) (Config, error) {
+	config := DefaultConfig()
+	if path == "" {
+		return config, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	err = json.Unmarshal(data, &config)
+	return config, err
+}
+
+// ApplyEnv overrides the names set in the RSSFETCHER_DB,
+// RSSFETCHER_FEED_COLLECTION, RSSFETCHER_NEWS_COLLECTION,
+// RSSFETCHER_CATEGORY_COLLECTION, RSSFETCHER_SUBCATEGORY_COLLECTION,
+// RSSFETCHER_ARCHIVE_COLLECTION, RSSFETCHER_CLICK_COLLECTION,
+// RSSFETCHER_WEBHOOK_COLLECTION, RSSFETCHER_DELIVERY_COLLECTION and
+// RSSFETCHER_BLOCKLIST_COLLECTION environment variables.
+func (c *Config) ApplyEnv() {
+	override(&c.Database, os.Getenv("RSSFETCHER_DB"))
+	override(&c.Feeds, os.Getenv("RSSFETCHER_FEED_COLLECTION"))
+	override(&c.News, os.Getenv("RSSFETCHER_NEWS_COLLECTION"))
+	override(&c.Categories, os.Getenv("RSSFETCHER_CATEGORY_COLLECTION"))
+	override(&c.SubCategories, os.Getenv("RSSFETCHER_SUBCATEGORY_COLLECTION"))
+	override(&c.Archive, os.Getenv("RSSFETCHER_ARCHIVE_COLLECTION"))
+	override(&c.Clicks, os.Getenv("RSSFETCHER_CLICK_COLLECTION"))
+	override(&c.Webhooks, os.Getenv("RSSFETCHER_WEBHOOK_COLLECTION"))
+	override(&c.Deliveries, os.Getenv("RSSFETCHER_DELIVERY_COLLECTION"))
+	override(&c.Blocklist, os.Getenv("RSSFETCHER_BLOCKLIST_COLLECTION"))
+}
+
+// Override sets the names that are not empty in o.
+func (c *Config) Override(o Config) {
+	override(&c.Database, o.Database)
+	override(&c.Feeds, o.Feeds)
+	override(&c.News, o.News)
+	override(&c.Categories, o.Categories)
+	override(&c.SubCategories, o.SubCategories)
+	override(&c.Archive, o.Archive)
+	override(&c.Clicks, o.Clicks)
+	override(&c.Webhooks, o.Webhooks)
+	override(&c.Deliveries, o.Deliveries)
+	override(&c.Blocklist, o.Blocklist)
+}
+
+func override(name *string, value string) {
+	if value != "" {
+		*name = value
+	}
+}
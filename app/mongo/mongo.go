@@ -4,12 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
-	"strings"
+	"regexp"
 	"time"
 
 	"github.com/jelinden/rssfetcher/app/domain"
 	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/store"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -19,9 +21,20 @@ import (
 
 type MongoRepository struct {
 	Client *mongo.Client
+	Config Config
 }
 
-var MongoClient MongoRepository
+var _ store.Store = (*MongoRepository)(nil)
+
+// NewMongoRepository connects to mongo and creates the indexes, failing
+// when the unique GUID index cannot be built.
+func NewMongoRepository(mongoAddress string, config Config) (*MongoRepository, error) {
+	m := &MongoRepository{Client: InitMongoClient(mongoAddress), Config: config}
+	if err := m.EnsureIndexes(context.Background()); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
 
 func InitMongoClient(mongoAddress string) *mongo.Client {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -37,104 +50,173 @@ func InitMongoClient(mongoAddress string) *mongo.Client {
 	return client
 }
 
-func SaveFeedItem(feed domain.Feed) {
-	if feed.SubCategory.ID.IsZero() {
-		feed.SubCategory = nil
+// EnsureIndexes creates the indexes of the collections. Only a failure of
+// the unique GUID index, which keeps concurrent fetches from storing an item
+// twice, is returned; the others are logged. Items stored twice before the
+// index existed are removed first.
+func (m *MongoRepository) EnsureIndexes(ctx context.Context) error {
+	c := m.collection(m.Config.News)
+	if err := m.removeDuplicateGUIDs(ctx, c); err != nil {
+		return fmt.Errorf("removing duplicate GUIDs: %w", err)
 	}
-	c := MongoClient.Client.Database("news").Collection("feedcollection")
+	_, err := c.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "rssGuid", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("creating unique GUID index: %w", err)
+	}
+	_, err = c.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "canonicalUrl", Value: 1}}},
+		{Keys: bson.D{{Key: "pubDate", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "clicks", Value: -1}, {Key: "pubDate", Value: -1}, {Key: "_id", Value: -1}}},
+		// The filters of the item listings, each sorted newest first. The
+		// category and subcategory match on either name, so both are
+		// indexed for the $or.
+		{Keys: newestFirst("category.enName")},
+		{Keys: newestFirst("category.categoryName")},
+		{Keys: newestFirst("subCategory.enName")},
+		{Keys: newestFirst("subCategory.subCategory")},
+		{Keys: newestFirst("language")},
+		{Keys: newestFirst("feedId")},
+		{Keys: newestFirst("clusterId")},
+	})
+	if err != nil {
+		log.Println("creating indexes failed", err)
+	}
+	_, err = m.collection(m.Config.Clicks).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "itemId", Value: 1}, {Key: "hour", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Println("creating click counter index failed", err)
+	}
+	_, err = m.collection(m.Config.Deliveries).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "created", Value: -1}}},
+		{Keys: bson.D{{Key: "webhookId", Value: 1}, {Key: "created", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "created", Value: -1}}},
+	})
+	if err != nil {
+		log.Println("creating delivery indexes failed", err)
+	}
+	return nil
+}
 
-	log.Println("url: "+feed.URL, "updating, ID:", feed.ID.Hex())
+// newestFirst is an index on field sorted like the item listings.
+func newestFirst(field string) bson.D {
+	return bson.D{{Key: field, Value: 1}, {Key: "pubDate", Value: -1}, {Key: "_id", Value: -1}}
+}
 
-	_, err := c.UpdateOne(context.Background(),
-		bson.D{{Key: "_id", Value: feed.ID}},
-		bson.D{{Key: "$set", Value: feed}})
+// removeDuplicateGUIDs keeps one item of each GUID, the most clicked and
+// then the oldest, and deletes the others.
+func (m *MongoRepository) removeDuplicateGUIDs(ctx context.Context, c *mongo.Collection) error {
+	cursor, err := c.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "clicks", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$rssGuid", "ids": bson.M{"$push": "$_id"}, "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	}, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	var duplicates []struct {
+		IDs []primitive.ObjectID `bson:"ids"`
+	}
+	if err := cursor.All(ctx, &duplicates); err != nil {
+		return err
+	}
+	var remove []primitive.ObjectID
+	for _, d := range duplicates {
+		remove = append(remove, d.IDs[1:]...)
+	}
+	if len(remove) == 0 {
+		return nil
+	}
+	result, err := c.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": remove}})
 	if err != nil {
-		log.Println(err)
+		return err
 	}
+	log.Println("removed", result.DeletedCount, "items with a duplicate GUID")
+	return nil
+}
 
+func (m *MongoRepository) collection(name string) *mongo.Collection {
+	return m.Client.Database(m.Config.Database).Collection(name)
 }
 
-func SaveFeed(feed *domain.Feed, lang string, name string, url string, siteURL string, category rss.Category, subCategory rss.SubCategory) {
-	c := MongoClient.Client.Database("news").Collection("feedcollection")
-	if feed != nil {
-		log.Println("url: "+feed.URL, "updating, ID:", feed.ID)
-		feed := &domain.Feed{
-			ID:          feed.ID,
-			Name:        name,
-			URL:         url,
-			SiteURL:     siteURL,
-			Category:    category,
-			SubCategory: &subCategory,
-			Language:    lang}
-
-		_, err := c.UpdateOne(context.Background(),
-			bson.D{{Key: "_id", Value: feed.ID}},
-			bson.D{{Key: "$set", Value: feed}})
-		if err != nil {
-			log.Println(err)
-		}
-	} else {
-		log.Println("inserting", url, "ids", category.ID, subCategory.ID)
-		feed := &domain.Feed{
-			ID:          primitive.NewObjectID(),
-			Name:        name,
-			URL:         url,
-			SiteURL:     siteURL,
-			Category:    category,
-			SubCategory: &subCategory,
-			Language:    lang}
-		_, err := c.InsertOne(context.Background(), &feed)
-		if err != nil {
-			log.Println("insert failed", err)
-		}
+func (m *MongoRepository) Close(ctx context.Context) error {
+	return m.Client.Disconnect(ctx)
+}
+
+func (m *MongoRepository) SaveFeed(ctx context.Context, feed domain.Feed) error {
+	if feed.SubCategory != nil && feed.SubCategory.ID.IsZero() {
+		feed.SubCategory = nil
 	}
+	c := m.collection(m.Config.Feeds)
+
+	log.Println("url: "+feed.URL, "saving, ID:", feed.ID.Hex())
+
+	_, err := c.ReplaceOne(ctx,
+		bson.D{{Key: "_id", Value: feed.ID}},
+		feed,
+		options.Replace().SetUpsert(true))
+	return err
+}
+
+func (m *MongoRepository) SavePublisher(ctx context.Context, feedID primitive.ObjectID, publisher domain.Publisher) error {
+	c := m.collection(m.Config.Feeds)
+	_, err := c.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: feedID}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "publisher", Value: publisher}}}})
+	return err
 }
 
-func SaveCategory(cat rss.Category) {
-	c := MongoClient.Client.Database("news").Collection("categorycollection")
+func (m *MongoRepository) SaveCategory(ctx context.Context, cat rss.Category) error {
+	c := m.collection(m.Config.Categories)
 	category := rss.Category{}
-	result := c.FindOne(context.Background(), bson.M{"categoryName": cat.Name})
+	result := c.FindOne(ctx, bson.M{"categoryName": cat.Name})
 	err := result.Decode(&category)
-	if err != nil {
-		log.Println("err", err)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
 	}
 	if category.ID.IsZero() {
-		_, err = c.InsertOne(context.Background(), cat)
-		if err != nil {
-			log.Println("err", err)
-		}
+		_, err = c.InsertOne(ctx, cat)
+		return err
 	}
+	return nil
 }
 
-func SaveSubCategory(subCat rss.SubCategory) {
-	c := MongoClient.Client.Database("news").Collection("subcategorycollection")
+func (m *MongoRepository) SaveSubCategory(ctx context.Context, subCat rss.SubCategory) error {
+	c := m.collection(m.Config.SubCategories)
 	subCategory := rss.SubCategory{}
-	result := c.FindOne(context.Background(), bson.M{"subCategory": subCat.SubCategory})
+	result := c.FindOne(ctx, bson.M{"subCategory": subCat.SubCategory})
 	err := result.Decode(&subCategory)
-	if err != nil {
-		log.Println("err", err)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
 	}
 	if subCategory.ID.IsZero() {
-		c.InsertOne(context.Background(), subCat)
+		_, err = c.InsertOne(ctx, subCat)
+		return err
 	}
+	return nil
 }
 
-func GetFeed(feedID string) (*domain.Feed, error) {
-	c := MongoClient.Client.Database("news").Collection("feedcollection")
+func (m *MongoRepository) GetFeed(ctx context.Context, feedID string) (*domain.Feed, error) {
+	c := m.collection(m.Config.Feeds)
 	log.Println("loading " + feedID)
 	if feedID == "" {
 		return nil, errors.New("feedID was empty")
 	}
 	feedId, err := primitive.ObjectIDFromHex(feedID)
 	if err != nil {
-		log.Println(err)
 		return nil, err
 	}
-	result := c.FindOne(context.Background(), bson.M{"_id": feedId})
+	result := c.FindOne(ctx, bson.M{"_id": feedId})
 	var feed domain.Feed
 	err = result.Decode(&feed)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, store.ErrNotFound
+	}
 	if err != nil {
-		log.Println(err)
 		return nil, err
 	}
 	feedAsJSON, _ := json.Marshal(feed)
@@ -142,170 +224,386 @@ func GetFeed(feedID string) (*domain.Feed, error) {
 	return &feed, nil
 }
 
-type feedStruct struct {
-	RSSFeed domain.Feed
-	Item    rss.Feed
+func (m *MongoRepository) GetItem(ctx context.Context, itemID string) (*rss.Item, error) {
+	id, err := primitive.ObjectIDFromHex(itemID)
+	if err != nil {
+		return nil, store.ErrNotFound
+	}
+	c := m.collection(m.Config.News)
+	var item rss.Item
+	err = c.FindOne(ctx, bson.M{"_id": id}).Decode(&item)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
 }
 
-func GetNews(feeds []domain.Feed) {
-	if len(feeds) > 0 {
-		log.Println("getting news")
-		c := make(chan *feedStruct)
-		go getNewsFeeds(feeds, c)
-		counter := 0
-		t := time.Now()
-		for i := range c {
-			counter++
-			if i != nil {
-				saveNewsItems(i.Item, i.RSSFeed)
-				log.Println("feed", i.RSSFeed.Name, i.RSSFeed.Category.Name, time.Since(t).Seconds(), "s")
-			}
-			if counter == len(feeds) {
-				close(c)
+// SaveItems upserts the items of one feed in a single bulk write. The
+// values that must survive updates are only written on insert. The stored
+// items are read first to tell which updates changed anything.
+func (m *MongoRepository) SaveItems(ctx context.Context, items []rss.Item) ([]store.ItemChange, error) {
+	changes := []store.ItemChange{}
+	if len(items) == 0 {
+		return changes, nil
+	}
+	collection := m.collection(m.Config.News)
+	stored, err := m.storedItems(ctx, collection, items)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	ids := make([]primitive.ObjectID, len(items))
+	models := make([]mongo.WriteModel, 0, len(items))
+	for i, item := range items {
+		set, err := toSetDocument(item, "_id", "rssGuid", "pubDate", "clicks", "updated")
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = primitive.NewObjectID()
+		onInsert := bson.M{
+			"_id":     ids[i],
+			"rssGuid": item.GUID,
+			"clicks":  item.Clicks,
+		}
+		// Like store.Merge, a stored date is only replaced when it is unset
+		// and the update time only when the item changes.
+		old, ok := stored[i]
+		if ok && old.Date.Unix() <= 0 {
+			set["pubDate"] = item.Date
+		} else {
+			onInsert["pubDate"] = item.Date
+		}
+		if !ok || store.Changed(old, item) {
+			set["updated"] = now
+		}
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"$or": []bson.M{
+				{"rssGuid": item.GUID},
+				{"canonicalUrl": item.CanonicalURL},
+			}}).
+			SetUpdate(bson.D{
+				{Key: "$set", Value: set},
+				{Key: "$setOnInsert", Value: onInsert},
+			}).
+			SetUpsert(true))
+	}
+	result, err := collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err := ignoreDuplicates(err); err != nil {
+		return nil, err
+	}
+	for i, item := range items {
+		if old, ok := stored[i]; ok {
+			if item = store.Merge(old, item); store.Changed(old, item) {
+				changes = append(changes, store.ItemChange{Item: item, Updated: true})
 			}
+		} else if _, ok := result.UpsertedIDs[int64(i)]; ok {
+			item.ID = ids[i]
+			item.Updated = now
+			changes = append(changes, store.ItemChange{Item: item})
 		}
-		log.Println("got all and closed the channel")
 	}
+	return changes, nil
 }
 
-func getNewsFeeds(feeds []domain.Feed, c chan *feedStruct) {
-	for i := range feeds {
-		go getNewsFeed(feeds, c, i)
+// storedItems returns the stored items matching items on GUID or canonical
+// link, keyed by the index of the item they match.
+func (m *MongoRepository) storedItems(ctx context.Context, collection *mongo.Collection, items []rss.Item) (map[int]rss.Item, error) {
+	guids := make([]string, len(items))
+	links := make([]string, len(items))
+	for i, item := range items {
+		guids[i], links[i] = item.GUID, item.CanonicalURL
+	}
+	cursor, err := collection.Find(ctx, bson.M{"$or": []bson.M{
+		{"rssGuid": bson.M{"$in": guids}},
+		{"canonicalUrl": bson.M{"$in": links}},
+	}})
+	if err != nil {
+		return nil, err
 	}
+	var found []rss.Item
+	if err := cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
+	stored := make(map[int]rss.Item)
+	for i, item := range items {
+		for _, f := range found {
+			if f.GUID == item.GUID || f.CanonicalURL == item.CanonicalURL {
+				stored[i] = f
+				break
+			}
+		}
+	}
+	return stored, nil
 }
 
-func getNewsFeed(feeds []domain.Feed, c chan *feedStruct, i int) {
-	item, err := rss.Fetch(feeds[i].URL)
+func toSetDocument(v interface{}, omit ...string) (bson.M, error) {
+	data, err := bson.Marshal(v)
 	if err != nil {
-		log.Println("err", feeds[i].URL, err)
-		c <- nil
-	} else {
-		items := feedStruct{RSSFeed: feeds[i], Item: *item}
-		c <- &items
+		return nil, err
 	}
+	doc := bson.M{}
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	for _, key := range omit {
+		delete(doc, key)
+	}
+	return doc, nil
 }
 
-func saveNewsItems(items rss.Feed, feed domain.Feed) {
-	collection := MongoClient.Client.Database("news").Collection("newscollection")
-	for k, item := range items.Items {
-		if k > 4 {
-			break
-		}
-		item.Title = strings.TrimSpace(item.Title)
-		item.Link = strings.TrimSpace(item.Link)
-		item.Content = strings.TrimSpace(item.Content)
-		item.GUID = strings.TrimSpace(item.GUID)
-		if item.Title != "" && item.Link != "" {
-			item.Language = feed.Language
-			item.Category = feed.Category
-			if !feed.SubCategory.ID.IsZero() {
-				item.SubCategory = feed.SubCategory
-			} else {
-				item.SubCategory = nil
-			}
-			item.Source = feed.Name
-			item.Language = feed.Language
-
-			if item.Date.After(time.Now()) {
-				item.Date = time.Now()
-			}
-			result := rss.Item{}
-			if len(item.GUID) > 3 {
-				item.GUID = getGUID(item.GUID)
-				r := collection.FindOne(context.Background(), bson.M{"rssGuid": item.GUID})
-				err := r.Decode(&result)
-				if err == nil {
-					item.ID = result.ID
-					if result.Date.Unix() > 0 {
-						item.Date = result.Date
-					}
-					item.Clicks = result.Clicks
-
-					_, err2 := collection.UpdateOne(context.Background(), bson.M{"_id": item.ID}, bson.D{{Key: "$set", Value: item}})
-					if err2 != nil {
-						log.Println("updating rss with id failed " + err2.Error())
-					}
-				} else if len(item.GUID) != 0 {
-					item.ID = primitive.NewObjectID()
-					_, err3 := collection.InsertOne(context.Background(), &item)
-					if err3 != nil {
-						log.Println("inserting rss failed " + err3.Error())
-					}
-				}
-			}
+// ignoreDuplicates drops the duplicate key errors of a concurrent tick
+// inserting the same GUID, the item is stored either way.
+func ignoreDuplicates(err error) error {
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+		return err
+	}
+	for _, writeErr := range bulkErr.WriteErrors {
+		if writeErr.Code != 11000 {
+			return err
 		}
 	}
+	return nil
 }
 
-func getGUID(guid string) string {
-	return strings.Replace(strings.Replace(guid, "http://", "", 1), "https://", "", 1)
-}
-
-func GetFeeds(args ...bool) []domain.Feed {
+func (m *MongoRepository) GetFeeds(ctx context.Context, activeOnly bool) ([]domain.Feed, error) {
 	var query = make(map[string]interface{})
-	if len(args) == 1 && args[0] {
+	if activeOnly {
 		query = bson.M{"removed": bson.M{"$ne": true}}
 	} else {
 		query = bson.M{}
 	}
-	c := MongoClient.Client.Database("news").Collection("feedcollection")
+	c := m.collection(m.Config.Feeds)
 	feedList := []domain.Feed{}
 
-	cursor, err := c.Find(context.Background(), query)
+	cursor, err := c.Find(ctx, query)
 	if err != nil {
-		log.Println(err)
-		return nil
+		return nil, err
 	}
-	if err = cursor.All(context.Background(), &feedList); err != nil {
-		log.Println(err)
-		return nil
+	if err = cursor.All(ctx, &feedList); err != nil {
+		return nil, err
 	}
-	return feedList
+	return feedList, nil
 }
 
-func GetCategories() []rss.Category {
-	c := MongoClient.Client.Database("news").Collection("categorycollection")
+func (m *MongoRepository) GetCategories(ctx context.Context) ([]rss.Category, error) {
+	c := m.collection(m.Config.Categories)
 	categoryList := []rss.Category{}
-	cursor, err := c.Find(context.Background(), bson.M{})
+	cursor, err := c.Find(ctx, bson.M{})
 	if err != nil {
-		log.Println(err)
-		return nil
+		return nil, err
 	}
-	if err := cursor.All(context.Background(), &categoryList); err != nil {
-		log.Println(err)
-		return nil
+	if err := cursor.All(ctx, &categoryList); err != nil {
+		return nil, err
 	}
-	return categoryList
+	return categoryList, nil
 }
 
-func GetSubCategories() []rss.SubCategory {
-	c := MongoClient.Client.Database("news").Collection("subcategorycollection")
+func (m *MongoRepository) GetSubCategories(ctx context.Context) ([]rss.SubCategory, error) {
+	c := m.collection(m.Config.SubCategories)
 	subCategoryList := []rss.SubCategory{}
-	cursor, err := c.Find(context.Background(), bson.M{})
+	cursor, err := c.Find(ctx, bson.M{})
 	if err != nil {
-		log.Println(err)
-		return nil
+		return nil, err
 	}
-	if err = cursor.All(context.Background(), &subCategoryList); err != nil {
-		log.Println(err)
+	if err = cursor.All(ctx, &subCategoryList); err != nil {
+		return nil, err
 	}
-	return subCategoryList
+	return subCategoryList, nil
 }
 
-func GetCategory(name string) rss.Category {
-
-	c := MongoClient.Client.Database("news").Collection("categorycollection")
+func (m *MongoRepository) GetCategory(ctx context.Context, name string) (rss.Category, error) {
+	c := m.collection(m.Config.Categories)
 	category := rss.Category{}
-	result := c.FindOne(context.Background(), bson.M{"categoryName": name})
-	result.Decode(&category)
-	return category
+	err := c.FindOne(ctx, bson.M{"categoryName": name}).Decode(&category)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return category, store.ErrNotFound
+	}
+	return category, err
 }
 
-func GetSubCategory(name string) rss.SubCategory {
-
-	c := MongoClient.Client.Database("news").Collection("subcategorycollection")
+func (m *MongoRepository) GetSubCategory(ctx context.Context, name string) (rss.SubCategory, error) {
+	c := m.collection(m.Config.SubCategories)
 	subCategory := rss.SubCategory{}
-	result := c.FindOne(context.Background(), bson.M{"subCategory": name})
-	result.Decode(&subCategory)
-	return subCategory
+	err := c.FindOne(ctx, bson.M{"subCategory": name}).Decode(&subCategory)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return subCategory, store.ErrNotFound
+	}
+	return subCategory, err
+}
+
+func (m *MongoRepository) FindItems(ctx context.Context, q store.ItemQuery) ([]rss.Item, error) {
+	sort := bson.D{{Key: "pubDate", Value: -1}, {Key: "_id", Value: -1}}
+	if q.ByClicks {
+		sort = append(bson.D{{Key: "clicks", Value: -1}}, sort...)
+	} else if q.Oldest {
+		sort = bson.D{{Key: "pubDate", Value: 1}, {Key: "_id", Value: 1}}
+	}
+	opts := options.Find().
+		SetSort(sort).
+		SetSkip(int64(q.Skip))
+	if q.Limit > 0 {
+		opts.SetLimit(int64(q.Limit))
+	}
+	cursor, err := m.collection(m.Config.News).Find(ctx, itemFilter(q), opts)
+	if err != nil {
+		return nil, err
+	}
+	items := []rss.Item{}
+	if err = cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func itemFilter(q store.ItemQuery) bson.M {
+	filter := bson.M{}
+	and := []bson.M{}
+	if !q.WithHidden {
+		filter["hidden"] = bson.M{"$ne": true}
+	}
+	if q.Category != "" {
+		and = append(and, bson.M{"$or": []bson.M{
+			{"category.enName": q.Category},
+			{"category.categoryName": q.Category},
+		}})
+	}
+	if q.SubCategory != "" {
+		and = append(and, bson.M{"$or": []bson.M{
+			{"subCategory.enName": q.SubCategory},
+			{"subCategory.subCategory": q.SubCategory},
+		}})
+	}
+	if len(q.NotCategories) > 0 {
+		filter["category.enName"] = bson.M{"$nin": q.NotCategories}
+		filter["category.categoryName"] = bson.M{"$nin": q.NotCategories}
+	}
+	if len(q.IDs) > 0 {
+		filter["_id"] = bson.M{"$in": q.IDs}
+	}
+	if q.Source != "" {
+		filter["rssSource"] = q.Source
+	}
+	if !q.FeedID.IsZero() {
+		filter["feedId"] = q.FeedID
+	}
+	if q.Language != "" {
+		filter["language"] = q.Language
+	}
+	if !q.ClusterID.IsZero() {
+		filter["clusterId"] = q.ClusterID
+	}
+	if q.Representatives {
+		and = append(and, bson.M{"$or": []bson.M{
+			{"clusterId": bson.M{"$exists": false}},
+			{"representative": true},
+		}})
+	}
+	pubDate := bson.M{}
+	if !q.After.IsZero() {
+		pubDate["$gte"] = q.After
+	}
+	if !q.Before.IsZero() {
+		pubDate["$lt"] = q.Before
+	}
+	if len(pubDate) > 0 {
+		filter["pubDate"] = pubDate
+	}
+	clicks := bson.M{}
+	if q.ClicksBelow > 0 {
+		clicks["$lt"] = q.ClicksBelow
+	}
+	if q.ClicksAtLeast > 0 {
+		clicks["$gte"] = q.ClicksAtLeast
+	}
+	if len(clicks) > 0 {
+		filter["clicks"] = clicks
+	}
+	if q.Text != "" {
+		text := primitive.Regex{Pattern: regexp.QuoteMeta(q.Text), Options: "i"}
+		and = append(and, bson.M{"$or": []bson.M{
+			{"rssTitle": text},
+			{"rssDesc": text},
+		}})
+	}
+	if c := q.Cursor; c != nil {
+		and = append(and, cursorFilter(q, c))
+	}
+	if len(and) > 0 {
+		filter["$and"] = and
+	}
+	return filter
+}
+
+// cursorFilter matches the items sorted after the cursor.
+func cursorFilter(q store.ItemQuery, c *store.ItemCursor) bson.M {
+	next := "$lt"
+	if q.Oldest && !q.ByClicks {
+		next = "$gt"
+	}
+	or := []bson.M{
+		{"pubDate": bson.M{next: c.Date}},
+		{"pubDate": c.Date, "_id": bson.M{next: c.ID}},
+	}
+	if q.ByClicks {
+		for _, cond := range or {
+			cond["clicks"] = c.Clicks
+		}
+		or = append([]bson.M{{"clicks": bson.M{"$lt": c.Clicks}}}, or...)
+	}
+	return bson.M{"$or": or}
+}
+
+func (m *MongoRepository) DeleteItems(ctx context.Context, ids []primitive.ObjectID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := m.collection(m.Config.News).DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	return err
+}
+
+func (m *MongoRepository) ArchiveItems(ctx context.Context, items []rss.Item) error {
+	if len(items) == 0 {
+		return nil
+	}
+	models := make([]mongo.WriteModel, 0, len(items))
+	for _, item := range items {
+		models = append(models, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"_id": item.ID}).
+			SetReplacement(item).
+			SetUpsert(true))
+	}
+	_, err := m.collection(m.Config.Archive).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func (m *MongoRepository) SetClusters(ctx context.Context, updates []store.ClusterUpdate) error {
+	if len(updates) == 0 {
+		return nil
+	}
+	models := make([]mongo.WriteModel, 0, len(updates))
+	for _, u := range updates {
+		update := bson.D{{Key: "$set", Value: bson.M{"clusterId": u.ClusterID, "representative": u.Representative}}}
+		if u.ClusterID.IsZero() {
+			update = bson.D{{Key: "$unset", Value: bson.M{"clusterId": "", "representative": ""}}}
+		}
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": u.ItemID}).
+			SetUpdate(update))
+	}
+	_, err := m.collection(m.Config.News).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func (m *MongoRepository) BackfillFeedID(ctx context.Context, source string, feedID primitive.ObjectID) (int, error) {
+	result, err := m.collection(m.Config.News).UpdateMany(ctx,
+		bson.M{"rssSource": source, "feedId": bson.M{"$exists": false}},
+		bson.D{{Key: "$set", Value: bson.M{"feedId": feedID}}})
+	if err != nil {
+		return 0, err
+	}
+	return int(result.ModifiedCount), nil
 }
@@ -0,0 +1,34 @@
+package mongo
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/jelinden/rssfetcher/app/store"
+	"github.com/jelinden/rssfetcher/app/store/storetest"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TestStore runs against the mongo at MONGO_URL, in a database of its own
+// for each test that is dropped afterwards.
+func TestStore(t *testing.T) {
+	address := os.Getenv("MONGO_URL")
+	if address == "" {
+		t.Skip("MONGO_URL is not set")
+	}
+	storetest.Run(t, func(t *testing.T) store.Store {
+		config := DefaultConfig()
+		config.Database = "rssfetcher_test_" + primitive.NewObjectID().Hex()
+		m, err := NewMongoRepository(address, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			ctx := context.Background()
+			m.Client.Database(config.Database).Drop(ctx)
+			m.Close(ctx)
+		})
+		return m
+	})
+}
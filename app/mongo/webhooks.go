@@ -0,0 +1,96 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/domain"
+	"github.com/jelinden/rssfetcher/app/store"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (m *MongoRepository) GetWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	cursor, err := m.collection(m.Config.Webhooks).Find(ctx, bson.M{},
+		options.Find().SetSort(bson.D{{Key: "created", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	webhooks := []domain.Webhook{}
+	if err := cursor.All(ctx, &webhooks); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (m *MongoRepository) GetWebhook(ctx context.Context, id primitive.ObjectID) (*domain.Webhook, error) {
+	var webhook domain.Webhook
+	err := m.collection(m.Config.Webhooks).FindOne(ctx, bson.M{"_id": id}).Decode(&webhook)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (m *MongoRepository) SaveWebhook(ctx context.Context, webhook domain.Webhook) error {
+	_, err := m.collection(m.Config.Webhooks).ReplaceOne(ctx,
+		bson.M{"_id": webhook.ID}, webhook, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (m *MongoRepository) DeleteWebhook(ctx context.Context, id primitive.ObjectID) error {
+	return m.deleteOne(ctx, m.Config.Webhooks, id)
+}
+
+func (m *MongoRepository) SaveDelivery(ctx context.Context, delivery domain.Delivery) error {
+	_, err := m.collection(m.Config.Deliveries).ReplaceOne(ctx,
+		bson.M{"_id": delivery.ID}, delivery, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (m *MongoRepository) GetDelivery(ctx context.Context, id primitive.ObjectID) (*domain.Delivery, error) {
+	var delivery domain.Delivery
+	err := m.collection(m.Config.Deliveries).FindOne(ctx, bson.M{"_id": id}).Decode(&delivery)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (m *MongoRepository) FindDeliveries(ctx context.Context, q store.DeliveryQuery) ([]domain.Delivery, error) {
+	filter := bson.M{}
+	if !q.WebhookID.IsZero() {
+		filter["webhookId"] = q.WebhookID
+	}
+	if q.Status != "" {
+		filter["status"] = q.Status
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created", Value: -1}})
+	if q.Limit > 0 {
+		opts.SetLimit(int64(q.Limit))
+	}
+	cursor, err := m.collection(m.Config.Deliveries).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	deliveries := []domain.Delivery{}
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (m *MongoRepository) DeleteDeliveries(ctx context.Context, before time.Time) error {
+	_, err := m.collection(m.Config.Deliveries).DeleteMany(ctx, bson.M{"created": bson.M{"$lt": before}})
+	return err
+}
@@ -0,0 +1,116 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"html"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/blocklist"
+	"github.com/jelinden/rssfetcher/app/domain"
+	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/rules"
+)
+
+// Trim removes the surrounding white space of the item fields.
+type Trim struct{}
+
+func (Trim) Process(ctx context.Context, feed domain.Feed, item *rss.Item) (bool, error) {
+	item.Title = strings.TrimSpace(item.Title)
+	item.Link = strings.TrimSpace(item.Link)
+	item.Content = strings.TrimSpace(item.Content)
+	item.GUID = strings.TrimSpace(item.GUID)
+	return true, nil
+}
+
+// TitleRewrite replaces the matches of a pattern in the title, for example
+// to strip a " | SiteName" suffix.
+type TitleRewrite struct {
+	Pattern *regexp.Regexp
+	Replace string
+}
+
+func newTitleRewrite(config domain.ProcessorConfig) (Processor, error) {
+	if config.Pattern == "" {
+		return nil, errors.New("titleRewrite needs a pattern")
+	}
+	re, err := regexp.Compile(config.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	return TitleRewrite{Pattern: re, Replace: config.Replace}, nil
+}
+
+func (t TitleRewrite) Process(ctx context.Context, feed domain.Feed, item *rss.Item) (bool, error) {
+	item.Title = strings.TrimSpace(t.Pattern.ReplaceAllString(item.Title, t.Replace))
+	return true, nil
+}
+
+// DecodeEntities decodes HTML entities such as &amp; left in the title.
+type DecodeEntities struct{}
+
+func (DecodeEntities) Process(ctx context.Context, feed domain.Feed, item *rss.Item) (bool, error) {
+	item.Title = strings.TrimSpace(html.UnescapeString(item.Title))
+	return true, nil
+}
+
+// Require drops items without a title, a link or a usable GUID.
+type Require struct{}
+
+func (Require) Process(ctx context.Context, feed domain.Feed, item *rss.Item) (bool, error) {
+	return item.Title != "" && item.Link != "" && len(item.GUID) > 3, nil
+}
+
+// Assign sets the fields an item takes from its feed, the canonical URL and
+// the GUID without the scheme.
+type Assign struct{}
+
+func (Assign) Process(ctx context.Context, feed domain.Feed, item *rss.Item) (bool, error) {
+	item.CanonicalURL = rss.CanonicalURL(item.Link)
+	item.GUID = strings.Replace(strings.Replace(item.GUID, "http://", "", 1), "https://", "", 1)
+	item.Language = feed.Language
+	item.Category = feed.Category
+	if feed.SubCategory != nil && !feed.SubCategory.ID.IsZero() {
+		item.SubCategory = feed.SubCategory
+	} else {
+		item.SubCategory = nil
+	}
+	item.Source = feed.Name
+	item.FeedID = feed.ID
+	return true, nil
+}
+
+// ClampDate moves dates in the future to the present.
+type ClampDate struct{}
+
+func (ClampDate) Process(ctx context.Context, feed domain.Feed, item *rss.Item) (bool, error) {
+	if now := time.Now(); item.Date.After(now) {
+		item.Date = now
+	}
+	return true, nil
+}
+
+// Rules drops the items the feed's filter rules do not keep.
+type Rules struct {
+	Rules *rules.Rules
+}
+
+func (r Rules) Process(ctx context.Context, feed domain.Feed, item *rss.Item) (bool, error) {
+	return r.Rules.Drop(*item) == "", nil
+}
+
+// Blocklist drops the items matching a global block rule.
+type Blocklist struct {
+	List *blocklist.List
+}
+
+func (b Blocklist) Process(ctx context.Context, feed domain.Feed, item *rss.Item) (bool, error) {
+	if rule, ok := b.List.Match(*item); ok {
+		log.Println("blocked", item.Link, "of", feed.Name+":", rule.Reason)
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,121 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jelinden/rssfetcher/app/domain"
+	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/rules"
+)
+
+// Processor changes a fetched item before it is stored, or drops it by
+// returning false.
+type Processor interface {
+	Process(ctx context.Context, feed domain.Feed, item *rss.Item) (keep bool, err error)
+}
+
+// Chain runs processors in order, stopping at the first that drops the item
+// or fails.
+type Chain []Processor
+
+func (c Chain) Process(ctx context.Context, feed domain.Feed, item *rss.Item) (bool, error) {
+	for _, p := range c {
+		keep, err := p.Process(ctx, feed, item)
+		if err != nil || !keep {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// builders make the processors that can be configured per feed.
+var builders = map[string]func(domain.ProcessorConfig) (Processor, error){
+	"titleRewrite":   newTitleRewrite,
+	"decodeEntities": func(domain.ProcessorConfig) (Processor, error) { return DecodeEntities{}, nil },
+}
+
+// Names lists the processors that can be configured per feed.
+func Names() []string {
+	names := make([]string, 0, len(builders))
+	for name := range builders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Error reports an invalid processor, numbered from 1.
+type Error struct {
+	Number int
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return "processor " + strconv.Itoa(e.Number) + ": " + e.Err.Error()
+}
+
+// ForFeed returns the chain run on the items of a feed: trimming, the feed's
+// own processors, the checks and fields every stored item needs, date
+// clamping and last the feed's rules.
+func ForFeed(feed domain.Feed) (Chain, error) {
+	chain := Chain{Trim{}}
+	for i, config := range feed.Processors {
+		build, ok := builders[config.Name]
+		if !ok {
+			return nil, &Error{Number: i + 1, Err: fmt.Errorf("name must be one of %s", strings.Join(Names(), ", "))}
+		}
+		p, err := build(config)
+		if err != nil {
+			return nil, &Error{Number: i + 1, Err: err}
+		}
+		chain = append(chain, p)
+	}
+	chain = append(chain, Require{}, Assign{}, ClampDate{})
+	compiled, err := rules.Compile(feed)
+	if err != nil {
+		return nil, err
+	}
+	if !compiled.Empty() {
+		chain = append(chain, Rules{compiled})
+	}
+	return chain, nil
+}
+
+// Parse reads processors written one per line as their name, followed by
+// ": pattern => replacement" for the ones that take them.
+func Parse(text string) ([]domain.ProcessorConfig, error) {
+	var configs []domain.ProcessorConfig
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, args, _ := strings.Cut(line, ":")
+		pattern, replace, ok := strings.Cut(args, "=>")
+		if strings.TrimSpace(args) != "" && !ok {
+			return nil, &Error{Number: len(configs) + 1, Err: fmt.Errorf("must be written as name: pattern => replacement")}
+		}
+		configs = append(configs, domain.ProcessorConfig{
+			Name:    strings.TrimSpace(name),
+			Pattern: strings.TrimSpace(pattern),
+			Replace: strings.TrimSpace(replace),
+		})
+	}
+	return configs, nil
+}
+
+// Format writes processors the way Parse reads them.
+func Format(configs []domain.ProcessorConfig) string {
+	lines := make([]string, len(configs))
+	for i, config := range configs {
+		lines[i] = config.Name
+		if config.Pattern != "" || config.Replace != "" {
+			lines[i] = strings.TrimSpace(lines[i] + ": " + config.Pattern + " => " + config.Replace)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,303 @@
+package publish
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/rss"
+)
+
+// Channel describes a feed made of stored news items.
+type Channel struct {
+	Title       string
+	Description string
+	// Link is the page the feed is about, FeedURL where the feed itself is
+	// served.
+	Link     string
+	FeedURL  string
+	Language string
+	Updated  time.Time
+}
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language,omitempty"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Self          atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link,omitempty"`
+	Description string        `xml:"description,omitempty"`
+	GUID        rssGUID       `xml:"guid"`
+	PubDate     string        `xml:"pubDate,omitempty"`
+	Categories  []string      `xml:"category"`
+	Enclosure   *rssEnclosure `xml:"enclosure"`
+}
+
+type rssGUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length string `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// RSS writes the items as an RSS 2.0 document.
+func RSS(c Channel, items []rss.Item) ([]byte, error) {
+	doc := rssDocument{Version: "2.0", Atom: "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       c.Title,
+			Link:        c.Link,
+			Description: c.Description,
+			Language:    c.Language,
+			Self:        atomLink{Href: c.FeedURL, Rel: "self", Type: "application/rss+xml"},
+		}}
+	if !c.Updated.IsZero() {
+		doc.Channel.LastBuildDate = c.Updated.Format(time.RFC1123Z)
+	}
+	for _, item := range items {
+		next := rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Description: item.Content,
+			GUID:        rssGUID{IsPermaLink: "false", Value: item.GUID},
+			Categories:  categories(item),
+		}
+		if !item.Date.IsZero() {
+			next.PubDate = item.Date.Format(time.RFC1123Z)
+		}
+		if item.Enclosure.Url != "" {
+			next.Enclosure = &rssEnclosure{URL: item.Enclosure.Url, Length: "0", Type: enclosureType(item.Enclosure)}
+		}
+		doc.Channel.Items = append(doc.Channel.Items, next)
+	}
+	return marshalXML(doc)
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Language string      `xml:"xml:lang,attr,omitempty"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr,omitempty"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length string `xml:"length,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Author     atomAuthor     `xml:"author"`
+	Links      []atomLink     `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Summary    *atomText      `xml:"summary"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// Atom writes the items as an Atom 1.0 feed.
+func Atom(c Channel, items []rss.Item) ([]byte, error) {
+	updated := c.Updated
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+	feed := atomFeed{
+		Language: c.Language,
+		ID:       c.FeedURL,
+		Title:    c.Title,
+		Subtitle: c.Description,
+		Updated:  updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: c.FeedURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: c.Link, Rel: "alternate"},
+		},
+	}
+	for _, item := range items {
+		date := item.Date
+		if date.IsZero() {
+			date = updated
+		}
+		entry := atomEntry{
+			ID:      entryID(c.FeedURL, item),
+			Title:   item.Title,
+			Updated: date.UTC().Format(time.RFC3339),
+			Author:  atomAuthor{Name: item.Source},
+		}
+		if entry.Author.Name == "" {
+			entry.Author.Name = c.Title
+		}
+		if !item.Date.IsZero() {
+			entry.Published = entry.Updated
+		}
+		if item.Link != "" {
+			entry.Links = append(entry.Links, atomLink{Href: item.Link, Rel: "alternate"})
+		}
+		if item.Enclosure.Url != "" {
+			entry.Links = append(entry.Links, atomLink{Href: item.Enclosure.Url, Rel: "enclosure", Type: enclosureType(item.Enclosure)})
+		}
+		for _, term := range categories(item) {
+			entry.Categories = append(entry.Categories, atomCategory{Term: term})
+		}
+		if item.Content != "" {
+			entry.Summary = &atomText{Type: "html", Value: item.Content}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return marshalXML(feed)
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url,omitempty"`
+	FeedURL     string         `json:"feed_url,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Language    string         `json:"language,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string               `json:"id"`
+	URL           string               `json:"url,omitempty"`
+	Title         string               `json:"title,omitempty"`
+	ContentHTML   string               `json:"content_html"`
+	Image         string               `json:"image,omitempty"`
+	DatePublished string               `json:"date_published,omitempty"`
+	Authors       []jsonFeedAuthor     `json:"authors,omitempty"`
+	Tags          []string             `json:"tags,omitempty"`
+	Language      string               `json:"language,omitempty"`
+	Attachments   []jsonFeedAttachment `json:"attachments,omitempty"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedAttachment struct {
+	URL      string `json:"url"`
+	MimeType string `json:"mime_type"`
+}
+
+// JSONFeed writes the items as a JSON Feed 1.1 document.
+func JSONFeed(c Channel, items []rss.Item) ([]byte, error) {
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       c.Title,
+		HomePageURL: c.Link,
+		FeedURL:     c.FeedURL,
+		Description: c.Description,
+		Language:    c.Language,
+		Items:       []jsonFeedItem{},
+	}
+	for _, item := range items {
+		next := jsonFeedItem{
+			ID:          item.GUID,
+			URL:         item.Link,
+			Title:       item.Title,
+			ContentHTML: item.Content,
+			Tags:        categories(item),
+			Language:    item.Language,
+		}
+		if next.ID == "" {
+			next.ID = item.ID.Hex()
+		}
+		if !item.Date.IsZero() {
+			next.DatePublished = item.Date.UTC().Format(time.RFC3339)
+		}
+		if item.Source != "" {
+			next.Authors = []jsonFeedAuthor{{Name: item.Source}}
+		}
+		if item.Enclosure.Url != "" {
+			mimeType := enclosureType(item.Enclosure)
+			next.Attachments = []jsonFeedAttachment{{URL: item.Enclosure.Url, MimeType: mimeType}}
+			if strings.HasPrefix(mimeType, "image/") {
+				next.Image = item.Enclosure.Url
+			}
+		}
+		feed.Items = append(feed.Items, next)
+	}
+	return json.Marshal(feed)
+}
+
+func marshalXML(v interface{}) ([]byte, error) {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+func categories(item rss.Item) []string {
+	var terms []string
+	if item.Category.Name != "" {
+		terms = append(terms, item.Category.Name)
+	}
+	if item.SubCategory != nil && item.SubCategory.SubCategory != "" {
+		terms = append(terms, item.SubCategory.SubCategory)
+	}
+	return terms
+}
+
+// enclosureType is the enclosure's own type or one guessed from its file
+// extension. Feeds mostly enclose article images.
+func enclosureType(e rss.Enclosure) string {
+	if e.Type != "" {
+		return e.Type
+	}
+	if u, err := url.Parse(e.Url); err == nil {
+		if t := mime.TypeByExtension(path.Ext(u.Path)); t != "" {
+			return t
+		}
+	}
+	return "image/jpeg"
+}
+
+// entryID makes a tag URI for an item, as its GUID is not always a URI.
+func entryID(feedURL string, item rss.Item) string {
+	host := "localhost"
+	if u, err := url.Parse(feedURL); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+	return "tag:" + host + "," + item.ID.Timestamp().UTC().Format("2006-01-02") + ":" + item.ID.Hex()
+}
@@ -0,0 +1,44 @@
+package retention
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/store"
+)
+
+// StoreArchiver moves expired items to the store's archive, the
+// "newsarchive" collection in Mongo.
+type StoreArchiver struct {
+	Store store.Store
+}
+
+func (a StoreArchiver) Archive(ctx context.Context, items []rss.Item) error {
+	return a.Store.ArchiveItems(ctx, items)
+}
+
+// FileArchiver appends expired items to a JSON Lines file.
+type FileArchiver struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (a *FileArchiver) Archive(ctx context.Context, items []rss.Item) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := os.OpenFile(a.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, item := range items {
+		if err := enc.Encode(item); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
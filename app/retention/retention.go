@@ -0,0 +1,157 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const batchSize = 500
+
+// Policy decides how long news items are kept. Zero values keep items.
+type Policy struct {
+	// MaxAge applies to categories without an age of their own.
+	MaxAge time.Duration
+	// CategoryMaxAge is keyed by category enName or name.
+	CategoryMaxAge map[string]time.Duration
+	// MaxItemsPerFeed keeps only the newest items of each feed.
+	MaxItemsPerFeed int
+	// Items with at least ClickThreshold clicks are exempt from the limits
+	// above and are kept until ClickedMaxAge, forever if it is zero.
+	ClickThreshold int
+	ClickedMaxAge  time.Duration
+	// ClickCountAge is how long hourly click counters are kept.
+	ClickCountAge time.Duration
+	// DeliveryAge is how long the webhook delivery log is kept.
+	DeliveryAge time.Duration
+}
+
+// Archiver keeps expired items before they are deleted.
+type Archiver interface {
+	Archive(ctx context.Context, items []rss.Item) error
+}
+
+type Retention struct {
+	Store    store.Store
+	Policy   Policy
+	Archiver Archiver
+}
+
+func New(s store.Store, policy Policy, archiver Archiver) *Retention {
+	return &Retention{Store: s, Policy: policy, Archiver: archiver}
+}
+
+func (r *Retention) Run(d time.Duration) {
+	for ; ; <-time.After(d) {
+		n, err := r.Expire(context.Background())
+		if err != nil {
+			log.Println("expiring news items failed after", n, "items", err)
+			continue
+		}
+		log.Println("expired", n, "news items")
+	}
+}
+
+// Expire removes the items the policy no longer keeps and returns how many
+// were removed.
+func (r *Retention) Expire(ctx context.Context) (int, error) {
+	now := time.Now()
+	p := r.Policy
+	if p.ClickCountAge > 0 {
+		if err := r.Store.DeleteClickCounts(ctx, now.Add(-p.ClickCountAge)); err != nil {
+			return 0, err
+		}
+	}
+	if p.DeliveryAge > 0 {
+		if err := r.Store.DeleteDeliveries(ctx, now.Add(-p.DeliveryAge)); err != nil {
+			return 0, err
+		}
+	}
+	queries := []store.ItemQuery{}
+	configured := make([]string, 0, len(p.CategoryMaxAge))
+	for category, age := range p.CategoryMaxAge {
+		configured = append(configured, category)
+		if age > 0 {
+			queries = append(queries, store.ItemQuery{Category: category, Before: now.Add(-age), ClicksBelow: p.ClickThreshold})
+		}
+	}
+	if p.MaxAge > 0 {
+		queries = append(queries, store.ItemQuery{NotCategories: configured, Before: now.Add(-p.MaxAge), ClicksBelow: p.ClickThreshold})
+	}
+	if p.ClickThreshold > 0 && p.ClickedMaxAge > 0 {
+		queries = append(queries, store.ItemQuery{Before: now.Add(-p.ClickedMaxAge), ClicksAtLeast: p.ClickThreshold})
+	}
+	if p.MaxItemsPerFeed > 0 {
+		feeds, err := r.Store.GetFeeds(ctx, false)
+		if err != nil {
+			return 0, err
+		}
+		for _, feed := range feeds {
+			queries = append(queries, store.ItemQuery{FeedID: feed.ID, ClicksBelow: p.ClickThreshold, Skip: p.MaxItemsPerFeed})
+		}
+	}
+	total := 0
+	for _, q := range queries {
+		n, err := r.expire(ctx, q)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func (r *Retention) expire(ctx context.Context, q store.ItemQuery) (int, error) {
+	// Hidden items expire like the others.
+	q.WithHidden = true
+	q.Limit = batchSize
+	total := 0
+	for {
+		items, err := r.Store.FindItems(ctx, q)
+		if err != nil || len(items) == 0 {
+			return total, err
+		}
+		if r.Archiver != nil {
+			if err := r.Archiver.Archive(ctx, items); err != nil {
+				return total, err
+			}
+		}
+		ids := make([]primitive.ObjectID, len(items))
+		for i, item := range items {
+			ids[i] = item.ID
+		}
+		if err := r.Store.DeleteItems(ctx, ids); err != nil {
+			return total, err
+		}
+		total += len(items)
+		if len(items) < batchSize {
+			return total, nil
+		}
+	}
+}
+
+// ParseCategoryAges parses "sports=168h,economy=720h" into a map.
+func ParseCategoryAges(s string) (map[string]time.Duration, error) {
+	ages := make(map[string]time.Duration)
+	if strings.TrimSpace(s) == "" {
+		return ages, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid category age %q", pair)
+		}
+		age, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return nil, err
+		}
+		ages[strings.TrimSpace(name)] = age
+	}
+	return ages, nil
+}
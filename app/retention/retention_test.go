@@ -0,0 +1,54 @@
+package retention
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/memory"
+	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/store"
+)
+
+func TestExpireClickedItems(t *testing.T) {
+	s := memory.New()
+	ctx := context.Background()
+	old := time.Now().Add(-48 * time.Hour)
+	items := []rss.Item{
+		{GUID: "old-clicked", Date: old, Category: rss.Category{EnName: "sports"}},
+		{GUID: "old-unclicked", Date: old, Category: rss.Category{EnName: "sports"}},
+		{GUID: "new-clicked", Date: time.Now(), Category: rss.Category{EnName: "sports"}},
+	}
+	if _, err := s.SaveItems(ctx, items); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := s.FindItems(ctx, store.ItemQuery{})
+	for _, item := range stored {
+		if item.GUID != "old-unclicked" {
+			if err := s.AddClick(ctx, item.ID, time.Now()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Unclicked items have no age limit, clicked ones are kept a day.
+	r := New(s, Policy{ClickThreshold: 1, ClickedMaxAge: 24 * time.Hour}, nil)
+	n, err := r.Expire(ctx)
+	if err != nil || n != 1 {
+		t.Fatal("expired", n, err)
+	}
+	left, _ := s.FindItems(ctx, store.ItemQuery{Oldest: true})
+	if len(left) != 2 || left[0].GUID != "old-unclicked" || left[1].GUID != "new-clicked" {
+		t.Error("left", left)
+	}
+
+	// A category age expires the unclicked item but not the clicked one.
+	r.Policy.CategoryMaxAge = map[string]time.Duration{"sports": time.Hour}
+	if n, err := r.Expire(ctx); err != nil || n != 1 {
+		t.Fatal("expired", n, err)
+	}
+	left, _ = s.FindItems(ctx, store.ItemQuery{})
+	if len(left) != 1 || left[0].GUID != "new-clicked" {
+		t.Error("left", left)
+	}
+}
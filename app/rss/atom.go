@@ -53,6 +53,10 @@ func parseAtom(data []byte) (*Feed, error) {
 			}
 		}
 		next.GUID = item.GUID
+		for _, category := range item.Categories {
+			next.Tags = append(next.Tags, category.Term)
+		}
+		next.Tags = cleanTags(next.Tags)
 		next.Read = false
 		if item.Enclosure.Url != "" {
 			next.Enclosure = item.Enclosure
@@ -107,16 +111,21 @@ type atomFeed struct {
 }
 
 type atomItem struct {
-	XMLName   xml.Name  `xml:"entry"`
-	Title     string    `xml:"title"`
-	Content   string    `xml:"summary"`
-	Link      atomLink  `xml:"link"`
-	Date      string    `xml:"published"`
-	GUID      string    `xml:"id"`
-	Enclosure Enclosure `xml:"enclosure"`
-	Content2  string    `xml:",innerxml"`
-	Content3  string    `xml:"content"`
-	Media     []Media   `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	XMLName    xml.Name       `xml:"entry"`
+	Title      string         `xml:"title"`
+	Content    string         `xml:"summary"`
+	Link       atomLink       `xml:"link"`
+	Date       string         `xml:"published"`
+	GUID       string         `xml:"id"`
+	Enclosure  Enclosure      `xml:"enclosure"`
+	Content2   string         `xml:",innerxml"`
+	Content3   string         `xml:"content"`
+	Categories []atomCategory `xml:"category"`
+	Media      []Media        `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
 }
 
 type atomImage struct {
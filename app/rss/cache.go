@@ -0,0 +1,50 @@
+package rss
+
+import (
+	"sync"
+	"time"
+)
+
+type cacheEntry struct {
+	value   string
+	expires time.Time
+}
+
+// linkCache remembers a string result per item link so that enrichment
+// fetches each article page once instead of once per tick.
+type linkCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cacheEntry
+}
+
+func newLinkCache(ttl time.Duration) *linkCache {
+	return &linkCache{ttl: ttl, entries: make(map[string]cacheEntry)}
+}
+
+func (c *linkCache) get(link string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[link]
+	if !ok || time.Now().After(e.expires) {
+		return "", false
+	}
+	return e.value, true
+}
+
+func (c *linkCache) set(link, value string) {
+	c.setFor(link, value, c.ttl)
+}
+
+// setFor caches value for a time of its own, e.g. briefly after a failure.
+func (c *linkCache) setFor(link, value string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[link] = cacheEntry{value: value, expires: now.Add(ttl)}
+}
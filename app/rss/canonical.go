@@ -0,0 +1,96 @@
+package rss
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// TrackingParams lists query parameters removed by CanonicalURL. A trailing
+// "*" matches any parameter with that prefix.
+var TrackingParams = []string{
+	"utm_*",
+	"fbclid",
+	"gclid",
+	"dclid",
+	"msclkid",
+	"mc_cid",
+	"mc_eid",
+	"igshid",
+	"ocid",
+	"_ga",
+}
+
+// ParseTrackingParams reads a comma separated list of tracking parameters,
+// dropping spaces around them and empty ones.
+func ParseTrackingParams(s string) []string {
+	var params []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			params = append(params, p)
+		}
+	}
+	return params
+}
+
+// CanonicalURL normalizes link for deduplication: the scheme and host are
+// lower-cased, default ports, fragments, trailing slashes and tracking
+// parameters are removed and the remaining query is sorted.
+func CanonicalURL(link string) string {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || u.Host == "" {
+		return strings.TrimSpace(link)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		host = host + ":" + port
+	}
+	u.Host = host
+	u.Fragment = ""
+	u.RawFragment = ""
+	if len(u.Path) > 1 {
+		u.Path = strings.TrimRight(u.Path, "/")
+		u.RawPath = ""
+	}
+	if u.Path == "/" {
+		u.Path = ""
+	}
+	query := u.Query()
+	for key := range query {
+		if isTrackingParam(key) {
+			query.Del(key)
+		}
+	}
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	params := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, value := range query[key] {
+			params = append(params, url.QueryEscape(key)+"="+url.QueryEscape(value))
+		}
+	}
+	u.RawQuery = strings.Join(params, "&")
+	u.ForceQuery = false
+	// The scheme does not identify a story, http and https links are the same.
+	return strings.TrimPrefix(u.String(), u.Scheme+"://")
+}
+
+func isTrackingParam(key string) bool {
+	key = strings.ToLower(key)
+	for _, p := range TrackingParams {
+		p = strings.ToLower(p)
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(key, strings.TrimSuffix(p, "*")) {
+				return true
+			}
+		} else if key == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package rss
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseTrackingParams(t *testing.T) {
+	for s, want := range map[string][]string{
+		"":                          nil,
+		" , ":                       nil,
+		"utm_source,fbclid":         {"utm_source", "fbclid"},
+		"utm_source, fbclid ,,ref ": {"utm_source", "fbclid", "ref"},
+	} {
+		if got := ParseTrackingParams(s); !slices.Equal(got, want) {
+			t.Errorf("%q: got %q, want %q", s, got, want)
+		}
+	}
+}
+
+func TestCanonicalURLParsedTrackingParams(t *testing.T) {
+	defer func(params []string) { TrackingParams = params }(TrackingParams)
+	TrackingParams = ParseTrackingParams("utm_*, fbclid")
+	got := CanonicalURL("https://Example.com/story/?fbclid=x&utm_source=y&id=1")
+	if want := "example.com/story?id=1"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,106 @@
+package rss
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const maxPageSize = 1 << 20
+
+var ogImageCache = newLinkCache(48 * time.Hour)
+
+// failureTTL is how long a failed page fetch is remembered, so that a page
+// timing out is not fetched on every tick but is tried again soon.
+const failureTTL = 10 * time.Minute
+
+// EnrichImages fills in an Open Graph image for the first n items that have
+// no image of their own. Items using the channel logo count as having none.
+func EnrichImages(feed *Feed, n int) {
+	logo := ""
+	if feed.Image != nil {
+		logo = feed.Image.Url
+	}
+	for k, item := range feed.Items {
+		if k >= n {
+			break
+		}
+		if item.Link == "" || (item.Enclosure.Url != "" && item.Enclosure.Url != logo) {
+			continue
+		}
+		if img := OGImage(item.Link); img != "" {
+			item.Enclosure = Enclosure{Url: img}
+		}
+	}
+}
+
+// OGImage returns the og:image or twitter:image of the page at link, or an
+// empty string if the page has neither. Results are cached by link, failed
+// fetches only for failureTTL.
+func OGImage(link string) string {
+	if img, ok := ogImageCache.get(link); ok {
+		return img
+	}
+	img, err := fetchOGImage(link)
+	if err != nil {
+		log.Println("og:image", link, err)
+		ogImageCache.setFor(link, "", failureTTL)
+		return ""
+	}
+	ogImageCache.set(link, img)
+	return img
+}
+
+func fetchOGImage(link string) (string, error) {
+	doc, err := fetchDocument(link)
+	if err != nil {
+		return "", err
+	}
+	selectors := []string{
+		`meta[property="og:image:secure_url"]`,
+		`meta[property="og:image"]`,
+		`meta[name="twitter:image"]`,
+		`meta[property="twitter:image"]`,
+		`meta[name="twitter:image:src"]`,
+	}
+	for _, s := range selectors {
+		if content, ok := doc.Find(s).First().Attr("content"); ok && strings.TrimSpace(content) != "" {
+			return resolveReference(link, strings.TrimSpace(content)), nil
+		}
+	}
+	return "", nil
+}
+
+func fetchDocument(link string) (*goquery.Document, error) {
+	resp, err := client.Get(link)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return documentFromResponse(resp)
+}
+
+func documentFromResponse(resp *http.Response) (*goquery.Document, error) {
+	return goquery.NewDocumentFromReader(io.LimitReader(resp.Body, maxPageSize))
+}
+
+func resolveReference(base, ref string) string {
+	b, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return b.ResolveReference(r).String()
+}
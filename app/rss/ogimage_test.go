@@ -0,0 +1,39 @@
+package rss
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOGImageCachesFailuresBriefly(t *testing.T) {
+	fail := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`<html><head><meta property="og:image" content="/image.jpg"></head></html>`))
+	}))
+	defer server.Close()
+
+	link := server.URL + "/story"
+	if img := OGImage(link); img != "" {
+		t.Fatal("image of a failed fetch", img)
+	}
+	expires := ogImageCache.entries[link].expires
+	if expires.After(time.Now().Add(failureTTL)) {
+		t.Error("failure cached until", expires)
+	}
+
+	// Once the failure expires the page is fetched again.
+	delete(ogImageCache.entries, link)
+	fail = false
+	if img := OGImage(link); img != server.URL+"/image.jpg" {
+		t.Error("image", img)
+	}
+	if expires := ogImageCache.entries[link].expires; expires.Before(time.Now().Add(24 * time.Hour)) {
+		t.Error("image cached until", expires)
+	}
+}
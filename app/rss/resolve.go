@@ -0,0 +1,101 @@
+package rss
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const maxRedirects = 10
+
+var errTooManyRedirects = errors.New("too many redirects")
+
+var resolveClient = &http.Client{
+	Timeout:   client.Timeout,
+	Transport: client.Transport,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return errTooManyRedirects
+		}
+		return nil
+	},
+}
+
+var resolvedLinks = newLinkCache(7 * 24 * time.Hour)
+
+// ResolveLinks replaces the links of the first n items with their final
+// destination, keeping the feed's link in OriginalLink.
+func ResolveLinks(feed *Feed, n int) {
+	for k, item := range feed.Items {
+		if k >= n {
+			break
+		}
+		if item.Link == "" {
+			continue
+		}
+		if resolved := ResolveLink(item.Link); resolved != item.Link {
+			item.OriginalLink = item.Link
+			item.Link = resolved
+		}
+	}
+}
+
+// ResolveLink follows the redirects of link and returns the canonical URL of
+// the page it ends up at, or link itself when that fails. Resolutions are
+// cached by link, failures only for failureTTL.
+func ResolveLink(link string) string {
+	if resolved, ok := resolvedLinks.get(link); ok {
+		return resolved
+	}
+	resolved, err := resolveLink(link)
+	if err != nil {
+		log.Println("resolve", link, err)
+		resolvedLinks.setFor(link, link, failureTTL)
+		return link
+	}
+	resolvedLinks.set(link, resolved)
+	return resolved
+}
+
+func resolveLink(link string) (string, error) {
+	final := link
+	resp, err := resolveClient.Head(link)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode < 400 {
+			final = resp.Request.URL.String()
+			if !strings.Contains(resp.Header.Get("Content-Type"), "html") {
+				return final, nil
+			}
+		}
+	}
+	resp, err = resolveClient.Get(final)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+	final = resp.Request.URL.String()
+	if !strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		return final, nil
+	}
+	doc, err := documentFromResponse(resp)
+	if err != nil {
+		return final, nil
+	}
+	href, ok := doc.Find(`link[rel="canonical"]`).First().Attr("href")
+	if !ok || strings.TrimSpace(href) == "" {
+		return final, nil
+	}
+	canonical, err := url.Parse(resolveReference(final, strings.TrimSpace(href)))
+	if err != nil || canonical.Host == "" || (canonical.Scheme != "http" && canonical.Scheme != "https") {
+		return final, nil
+	}
+	return canonical.String(), nil
+}
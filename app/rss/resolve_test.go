@@ -0,0 +1,23 @@
+package rss
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestResolveLinkCachesFailuresBriefly(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	link := server.URL + "/story"
+	if resolved := ResolveLink(link); resolved != link {
+		t.Error("resolved a failing link to", resolved)
+	}
+	if expires := resolvedLinks.entries[link].expires; expires.After(time.Now().Add(failureTTL)) {
+		t.Error("failure cached until", expires)
+	}
+}
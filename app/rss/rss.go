@@ -81,19 +81,51 @@ type Image struct {
 }
 
 type Item struct {
-	ID          primitive.ObjectID `json:"id" bson:"_id"`
-	Title       string             `json:"rssTitle" bson:"rssTitle"`
-	Content     string             `json:"rssDesc" bson:"rssDesc"`
-	Link        string             `json:"rssLink" bson:"rssLink"`
-	Date        time.Time          `json:"pubDate" bson:"pubDate"`
-	GUID        string             `json:"rssGuid" bson:"rssGuid"`
-	Read        bool               `json:"read" bson:"read"`
-	Enclosure   Enclosure          `json:"enclosure" bson:"enclosure"`
-	Category    Category           `json:"category" bson:"category"`
-	SubCategory *SubCategory       `json:"subCategory" bson:"subCategory"`
-	Language    string             `json:"language" bson:"language"`
-	Source      string             `json:"rssSource" bson:"rssSource"`
-	Clicks      int                `json:"clicks" bson:"clicks"`
+	ID             primitive.ObjectID `json:"id" bson:"_id"`
+	Title          string             `json:"rssTitle" bson:"rssTitle"`
+	Content        string             `json:"rssDesc" bson:"rssDesc"`
+	Link           string             `json:"rssLink" bson:"rssLink"`
+	OriginalLink   string             `json:"originalLink" bson:"originalLink,omitempty"`
+	Date           time.Time          `json:"pubDate" bson:"pubDate"`
+	GUID           string             `json:"rssGuid" bson:"rssGuid"`
+	CanonicalURL   string             `json:"canonicalUrl" bson:"canonicalUrl"`
+	Read           bool               `json:"read" bson:"read"`
+	Enclosure      Enclosure          `json:"enclosure" bson:"enclosure"`
+	Tags           []string           `json:"tags" bson:"tags,omitempty"`
+	Category       Category           `json:"category" bson:"category"`
+	SubCategory    *SubCategory       `json:"subCategory" bson:"subCategory"`
+	Language       string             `json:"language" bson:"language"`
+	Source         string             `json:"rssSource" bson:"rssSource"`
+	FeedID         primitive.ObjectID `json:"feedId" bson:"feedId,omitempty"`
+	Clicks         int                `json:"clicks" bson:"clicks"`
+	ClusterID      primitive.ObjectID `json:"clusterId" bson:"clusterId,omitempty"`
+	Representative bool               `json:"representative" bson:"representative,omitempty"`
+	// Updated is when the item was stored, changed or shown again, zero for
+	// items stored before it was kept.
+	Updated time.Time `json:"updated" bson:"updated,omitempty"`
+	// Hidden items matched a block rule, HiddenBy, and are left out of
+	// listings.
+	Hidden       bool               `json:"hidden,omitempty" bson:"hidden,omitempty"`
+	HiddenBy     primitive.ObjectID `json:"hiddenBy,omitempty" bson:"hiddenBy,omitempty"`
+	HiddenReason string             `json:"hiddenReason,omitempty" bson:"hiddenReason,omitempty"`
+	// Icon is the URL of the feed's icon, set by the item APIs only.
+	Icon string `json:"icon,omitempty" bson:"-"`
+}
+
+// cleanTags trims the category terms of an item and drops empty and
+// repeated ones.
+func cleanTags(terms []string) []string {
+	var tags []string
+	seen := make(map[string]struct{})
+	for _, term := range terms {
+		term = strings.TrimSpace(term)
+		if _, ok := seen[term]; ok || term == "" {
+			continue
+		}
+		seen[term] = struct{}{}
+		tags = append(tags, term)
+	}
+	return tags
 }
 
 type Category struct {
@@ -107,6 +139,7 @@ type SubCategory struct {
 	ID          primitive.ObjectID `json:"id" bson:"_id"`
 	SubCategory string             `json:"subCategory" bson:"subCategory"`
 	EnName      string             `json:"enName" bson:"enName"`
+	ParentID    primitive.ObjectID `json:"parentId" bson:"parentId,omitempty"`
 }
 
 type Enclosure struct {
@@ -63,6 +63,7 @@ func parseRSS1(data []byte) (*Feed, error) {
 			}
 		}
 		next.GUID = item.GUID
+		next.Tags = cleanTags(item.Subjects)
 		next.Read = false
 		if item.Media != nil && item.Media[len(item.Media)-1].Url != "" {
 			enclosure := Enclosure{}
@@ -118,6 +119,7 @@ type rss1_0Item struct {
 	Date      string    `xml:"date"`
 	GUID      string    `xml:"guid"`
 	Enclosure Enclosure `xml:"enclosure"`
+	Subjects  []string  `xml:"http://purl.org/dc/elements/1.1/ subject"`
 	Media     []Media   `xml:"http://search.yahoo.com/mrss/ content"`
 }
 
@@ -63,6 +63,7 @@ func parseRSS2(data []byte) (*Feed, error) {
 			}
 		}
 		next.GUID = item.GUID
+		next.Tags = cleanTags(item.Categories)
 		next.Read = false
 		if item.Enclosure.Url != "" {
 			next.Enclosure = item.Enclosure
@@ -120,16 +121,17 @@ type rss2_0Channel struct {
 }
 
 type rss2_0Item struct {
-	XMLName   xml.Name  `xml:"item"`
-	Title     string    `xml:"title"`
-	Content   string    `xml:"description"`
-	Link      string    `xml:"link"`
-	PubDate   string    `xml:"pubDate"`
-	Date      string    `xml:"date"`
-	GUID      string    `xml:"guid"`
-	Enclosure Enclosure `xml:"enclosure"`
-	Media     []Media   `xml:"http://search.yahoo.com/mrss/ thumbnail"`
-	Media2    []Media   `xml:"http://search.yahoo.com/mrss/ content"`
+	XMLName    xml.Name  `xml:"item"`
+	Title      string    `xml:"title"`
+	Content    string    `xml:"description"`
+	Link       string    `xml:"link"`
+	PubDate    string    `xml:"pubDate"`
+	Date       string    `xml:"date"`
+	GUID       string    `xml:"guid"`
+	Enclosure  Enclosure `xml:"enclosure"`
+	Categories []string  `xml:"category"`
+	Media      []Media   `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	Media2     []Media   `xml:"http://search.yahoo.com/mrss/ content"`
 }
 
 type rss2_0Image struct {
@@ -0,0 +1,176 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jelinden/rssfetcher/app/domain"
+	"github.com/jelinden/rssfetcher/app/rss"
+)
+
+// Fields are the item fields rules can match.
+var Fields = []string{"title", "content", "link", "tags"}
+
+// Error reports an invalid rule, numbered from 1 in its list.
+type Error struct {
+	List   string
+	Number int
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return e.List + " rule " + strconv.Itoa(e.Number) + ": " + e.Err.Error()
+}
+
+type rule struct {
+	domain.FilterRule
+	re *regexp.Regexp
+}
+
+func (r rule) String() string {
+	return r.Field + ": " + r.Pattern
+}
+
+func (r rule) matches(item rss.Item) bool {
+	switch r.Field {
+	case "title":
+		return r.re.MatchString(item.Title)
+	case "content":
+		return r.re.MatchString(item.Content)
+	case "link":
+		return r.re.MatchString(item.Link)
+	case "tags":
+		return slices.ContainsFunc(item.Tags, r.re.MatchString)
+	}
+	return false
+}
+
+// Rules are the compiled filter rules of a feed.
+type Rules struct {
+	include        []rule
+	exclude        []rule
+	minTitleLength int
+}
+
+// Compile checks and compiles the rules of a feed.
+func Compile(feed domain.Feed) (*Rules, error) {
+	include, err := compile("include", feed.Include)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compile("exclude", feed.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	return &Rules{include: include, exclude: exclude, minTitleLength: feed.MinTitleLength}, nil
+}
+
+func compile(list string, filterRules []domain.FilterRule) ([]rule, error) {
+	compiled := make([]rule, len(filterRules))
+	for i, r := range filterRules {
+		if !slices.Contains(Fields, r.Field) {
+			return nil, &Error{List: list, Number: i + 1, Err: fmt.Errorf("field must be one of %s", strings.Join(Fields, ", "))}
+		}
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, &Error{List: list, Number: i + 1, Err: err}
+		}
+		compiled[i] = rule{FilterRule: r, re: re}
+	}
+	return compiled, nil
+}
+
+// Empty reports whether the rules keep every item.
+func (r *Rules) Empty() bool {
+	return len(r.include) == 0 && len(r.exclude) == 0 && r.minTitleLength == 0
+}
+
+// Drop returns why an item is not stored, or an empty string when it is.
+func (r *Rules) Drop(item rss.Item) string {
+	if r.shortTitle(item) {
+		return r.minTitleReason()
+	}
+	for _, ex := range r.exclude {
+		if ex.matches(item) {
+			return "exclude " + ex.String()
+		}
+	}
+	if len(r.include) > 0 && !r.included(item) {
+		return includeReason
+	}
+	return ""
+}
+
+const includeReason = "matches no include rule"
+
+func (r *Rules) minTitleReason() string {
+	return "title shorter than " + strconv.Itoa(r.minTitleLength)
+}
+
+func (r *Rules) shortTitle(item rss.Item) bool {
+	return utf8.RuneCountInString(item.Title) < r.minTitleLength
+}
+
+func (r *Rules) included(item rss.Item) bool {
+	for _, in := range r.include {
+		if in.matches(item) {
+			return true
+		}
+	}
+	return false
+}
+
+// DryRun returns for each rule the items it would drop on its own, the
+// include rules counting as one.
+func (r *Rules) DryRun(items []rss.Item) []domain.RuleResult {
+	var results []domain.RuleResult
+	add := func(name string, drops func(rss.Item) bool) {
+		result := domain.RuleResult{Rule: name}
+		for _, item := range items {
+			if drops(item) {
+				result.Items = append(result.Items, item)
+			}
+		}
+		results = append(results, result)
+	}
+	if r.minTitleLength > 0 {
+		add(r.minTitleReason(), r.shortTitle)
+	}
+	for _, ex := range r.exclude {
+		add("exclude "+ex.String(), ex.matches)
+	}
+	if len(r.include) > 0 {
+		add(includeReason, func(item rss.Item) bool { return !r.included(item) })
+	}
+	return results
+}
+
+// Parse reads rules written one per line as "field: pattern".
+func Parse(list, text string) ([]domain.FilterRule, error) {
+	var parsed []domain.FilterRule
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		field, pattern, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, &Error{List: list, Number: len(parsed) + 1, Err: fmt.Errorf("must be written as field: pattern")}
+		}
+		parsed = append(parsed, domain.FilterRule{Field: strings.TrimSpace(field), Pattern: strings.TrimSpace(pattern)})
+	}
+	return parsed, nil
+}
+
+// Format writes rules the way Parse reads them.
+func Format(filterRules []domain.FilterRule) string {
+	lines := make([]string, len(filterRules))
+	for i, r := range filterRules {
+		lines[i] = r.Field + ": " + r.Pattern
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,71 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Repository) HideItems(ctx context.Context, ids []primitive.ObjectID, rule domain.BlockRule) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := []interface{}{rule.ID.Hex(), rule.Reason}
+	for _, id := range ids {
+		args = append(args, id.Hex())
+	}
+	in := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	_, err := s.DB.ExecContext(ctx,
+		`UPDATE items SET doc = json_set(doc, '$.hidden', json('true'), '$.hiddenBy', ?, '$.hiddenReason', ?)
+		WHERE id IN (`+in+`)`, args...)
+	return err
+}
+
+func (s *Repository) UnhideItems(ctx context.Context, ruleID primitive.ObjectID) (int, error) {
+	result, err := s.DB.ExecContext(ctx,
+		`UPDATE items SET doc = json_set(json_remove(doc, '$.hidden', '$.hiddenBy', '$.hiddenReason'), '$.updated', ?)
+		WHERE json_extract(doc, '$.hidden') = 1 AND json_extract(doc, '$.hiddenBy') = ?`,
+		time.Now().Format(time.RFC3339Nano), ruleID.Hex())
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+func (s *Repository) GetBlockRules(ctx context.Context) ([]domain.BlockRule, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT doc FROM block_rules ORDER BY created`)
+	if err != nil {
+		return nil, err
+	}
+	rules := []domain.BlockRule{}
+	err = scanDocs(rows, func(doc []byte) error {
+		var rule domain.BlockRule
+		if err := json.Unmarshal(doc, &rule); err != nil {
+			return err
+		}
+		rules = append(rules, rule)
+		return nil
+	})
+	return rules, err
+}
+
+func (s *Repository) SaveBlockRule(ctx context.Context, rule domain.BlockRule) error {
+	doc, err := json.Marshal(rule)
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.ExecContext(ctx,
+		`INSERT INTO block_rules (id, created, doc) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET created = excluded.created, doc = excluded.doc`,
+		rule.ID.Hex(), rule.Created.UnixNano(), string(doc))
+	return err
+}
+
+func (s *Repository) DeleteBlockRule(ctx context.Context, id primitive.ObjectID) error {
+	return s.delete(ctx, "block_rules", id)
+}
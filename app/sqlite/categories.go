@@ -0,0 +1,140 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Repository) GetCategoryByID(ctx context.Context, id primitive.ObjectID) (rss.Category, error) {
+	var category rss.Category
+	err := s.getDoc(ctx, `SELECT doc FROM categories WHERE id = ?`, &category, id.Hex())
+	return category, err
+}
+
+func (s *Repository) UpdateCategory(ctx context.Context, category rss.Category) error {
+	doc, err := json.Marshal(category)
+	if err != nil {
+		return err
+	}
+	return s.update(ctx, "categories", category.ID, category.Name, doc)
+}
+
+func (s *Repository) CountCategoryRefs(ctx context.Context, id primitive.ObjectID) (int, error) {
+	return s.countRefs(ctx, "$.category", id)
+}
+
+func (s *Repository) ReassignCategory(ctx context.Context, from primitive.ObjectID, to rss.Category, limit int) (int, error) {
+	doc, err := json.Marshal(to)
+	if err != nil {
+		return 0, err
+	}
+	return s.reassign(ctx, "$.category", from, doc, limit)
+}
+
+func (s *Repository) DeleteCategory(ctx context.Context, id primitive.ObjectID) error {
+	return s.delete(ctx, "categories", id)
+}
+
+func (s *Repository) GetSubCategoryByID(ctx context.Context, id primitive.ObjectID) (rss.SubCategory, error) {
+	var subCategory rss.SubCategory
+	err := s.getDoc(ctx, `SELECT doc FROM subcategories WHERE id = ?`, &subCategory, id.Hex())
+	return subCategory, err
+}
+
+func (s *Repository) UpdateSubCategory(ctx context.Context, subCategory rss.SubCategory) error {
+	doc, err := json.Marshal(subCategory)
+	if err != nil {
+		return err
+	}
+	return s.update(ctx, "subcategories", subCategory.ID, subCategory.SubCategory, doc)
+}
+
+func (s *Repository) CountSubCategoryRefs(ctx context.Context, id primitive.ObjectID) (int, error) {
+	return s.countRefs(ctx, "$.subCategory", id)
+}
+
+func (s *Repository) ReassignSubCategory(ctx context.Context, from primitive.ObjectID, to rss.SubCategory, limit int) (int, error) {
+	doc, err := json.Marshal(to)
+	if err != nil {
+		return 0, err
+	}
+	return s.reassign(ctx, "$.subCategory", from, doc, limit)
+}
+
+func (s *Repository) DeleteSubCategory(ctx context.Context, id primitive.ObjectID) error {
+	return s.delete(ctx, "subcategories", id)
+}
+
+// update replaces a row of the categories or subcategories table.
+func (s *Repository) update(ctx context.Context, table string, id primitive.ObjectID, name string, doc []byte) error {
+	var n int
+	err := s.DB.QueryRowContext(ctx, `SELECT count(*) FROM `+table+` WHERE name = ? AND id != ?`, name, id.Hex()).Scan(&n)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return store.ErrDuplicate
+	}
+	result, err := s.DB.ExecContext(ctx, `UPDATE `+table+` SET name = ?, doc = ? WHERE id = ?`, name, string(doc), id.Hex())
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Repository) delete(ctx context.Context, table string, id primitive.ObjectID) error {
+	result, err := s.DB.ExecContext(ctx, `DELETE FROM `+table+` WHERE id = ?`, id.Hex())
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Repository) countRefs(ctx context.Context, path string, id primitive.ObjectID) (int, error) {
+	total := 0
+	for _, table := range []string{"feeds", "items"} {
+		var n int
+		err := s.DB.QueryRowContext(ctx,
+			`SELECT count(*) FROM `+table+` WHERE json_extract(doc, '`+path+`.id') = ?`, id.Hex()).Scan(&n)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// reassign replaces the object at path in at most limit feeds and items
+// embedding the one with ID from, feeds first.
+func (s *Repository) reassign(ctx context.Context, path string, from primitive.ObjectID, doc []byte, limit int) (int, error) {
+	total := 0
+	for _, table := range []string{"feeds", "items"} {
+		if total >= limit {
+			break
+		}
+		result, err := s.DB.ExecContext(ctx,
+			`UPDATE `+table+` SET doc = json_set(doc, '`+path+`', json(?)) WHERE id IN (
+				SELECT id FROM `+table+` WHERE json_extract(doc, '`+path+`.id') = ?
+				AND json_extract(doc, '`+path+`') IS NOT json(?) LIMIT ?)`,
+			string(doc), from.Hex(), string(doc), limit-total)
+		if err != nil {
+			return total, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += int(n)
+	}
+	return total, nil
+}
@@ -0,0 +1,65 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AddClick keeps the clicks column and the document in step.
+func (s *Repository) AddClick(ctx context.Context, itemID primitive.ObjectID, at time.Time) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	result, err := tx.ExecContext(ctx,
+		`UPDATE items SET clicks = clicks + 1, doc = json_set(doc, '$.clicks', clicks + 1) WHERE id = ?`,
+		itemID.Hex())
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return store.ErrNotFound
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO click_counts (item_id, hour, clicks) VALUES (?, ?, 1)
+		ON CONFLICT (item_id, hour) DO UPDATE SET clicks = clicks + 1`,
+		itemID.Hex(), at.Truncate(time.Hour).Unix())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Repository) ClickCounts(ctx context.Context, since time.Time) ([]store.ClickCount, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT item_id, hour, clicks FROM click_counts WHERE hour >= ? ORDER BY hour`,
+		since.Truncate(time.Hour).Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := []store.ClickCount{}
+	for rows.Next() {
+		var id string
+		var hour int64
+		var count store.ClickCount
+		if err := rows.Scan(&id, &hour, &count.Clicks); err != nil {
+			return nil, err
+		}
+		if count.ItemID, err = primitive.ObjectIDFromHex(id); err != nil {
+			return nil, err
+		}
+		count.Hour = time.Unix(hour, 0).UTC()
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+func (s *Repository) DeleteClickCounts(ctx context.Context, before time.Time) error {
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM click_counts WHERE hour < ?`, before.Unix())
+	return err
+}
@@ -0,0 +1,523 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/domain"
+	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"modernc.org/sqlite"
+)
+
+// Rows are stored as JSON documents using the json tags of the domain types.
+// The columns next to the document are copies used for lookups and ordering.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS feeds (
+		id TEXT PRIMARY KEY,
+		removed INTEGER NOT NULL DEFAULT 0,
+		doc TEXT NOT NULL)`,
+	`CREATE TABLE IF NOT EXISTS categories (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		doc TEXT NOT NULL)`,
+	`CREATE TABLE IF NOT EXISTS subcategories (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		doc TEXT NOT NULL)`,
+	`CREATE TABLE IF NOT EXISTS items (
+		id TEXT PRIMARY KEY,
+		guid TEXT NOT NULL UNIQUE,
+		canonical_url TEXT NOT NULL,
+		pub_date INTEGER NOT NULL,
+		clicks INTEGER NOT NULL DEFAULT 0,
+		doc TEXT NOT NULL)`,
+	`CREATE INDEX IF NOT EXISTS items_canonical_url ON items (canonical_url)`,
+	`CREATE INDEX IF NOT EXISTS items_pub_date ON items (pub_date)`,
+	`CREATE INDEX IF NOT EXISTS items_clicks ON items (clicks, pub_date)`,
+	`CREATE INDEX IF NOT EXISTS items_feed_id ON items (json_extract(doc, '$.feedId'), pub_date)`,
+	`CREATE INDEX IF NOT EXISTS items_source ON items (json_extract(doc, '$.rssSource'))`,
+	`CREATE TABLE IF NOT EXISTS items_archive (
+		id TEXT PRIMARY KEY,
+		doc TEXT NOT NULL)`,
+	`CREATE TABLE IF NOT EXISTS click_counts (
+		item_id TEXT NOT NULL,
+		hour INTEGER NOT NULL,
+		clicks INTEGER NOT NULL,
+		PRIMARY KEY (item_id, hour))`,
+	`CREATE INDEX IF NOT EXISTS click_counts_hour ON click_counts (hour)`,
+	`CREATE TABLE IF NOT EXISTS webhooks (
+		id TEXT PRIMARY KEY,
+		created INTEGER NOT NULL,
+		doc TEXT NOT NULL)`,
+	`CREATE TABLE IF NOT EXISTS deliveries (
+		id TEXT PRIMARY KEY,
+		webhook_id TEXT NOT NULL,
+		status TEXT NOT NULL,
+		created INTEGER NOT NULL,
+		doc TEXT NOT NULL)`,
+	`CREATE INDEX IF NOT EXISTS deliveries_created ON deliveries (created)`,
+	`CREATE INDEX IF NOT EXISTS deliveries_webhook_id ON deliveries (webhook_id, created)`,
+	`CREATE INDEX IF NOT EXISTS deliveries_status ON deliveries (status, created)`,
+	`CREATE TABLE IF NOT EXISTS block_rules (
+		id TEXT PRIMARY KEY,
+		created INTEGER NOT NULL,
+		doc TEXT NOT NULL)`,
+}
+
+type Repository struct {
+	DB *sql.DB
+}
+
+var _ store.Store = (*Repository)(nil)
+
+// Open opens or creates the database file at path.
+func Open(path string) (*Repository, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, one connection avoids busy errors.
+	db.SetMaxOpenConns(1)
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return &Repository{DB: db}, nil
+}
+
+func (s *Repository) Close(ctx context.Context) error {
+	return s.DB.Close()
+}
+
+func (s *Repository) GetFeeds(ctx context.Context, activeOnly bool) ([]domain.Feed, error) {
+	query := `SELECT doc FROM feeds`
+	if activeOnly {
+		query += ` WHERE removed = 0`
+	}
+	rows, err := s.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	feedList := []domain.Feed{}
+	err = scanDocs(rows, func(doc []byte) error {
+		var feed domain.Feed
+		if err := json.Unmarshal(doc, &feed); err != nil {
+			return err
+		}
+		feedList = append(feedList, feed)
+		return nil
+	})
+	return feedList, err
+}
+
+func (s *Repository) GetFeed(ctx context.Context, feedID string) (*domain.Feed, error) {
+	if feedID == "" {
+		return nil, errors.New("feedID was empty")
+	}
+	if _, err := primitive.ObjectIDFromHex(feedID); err != nil {
+		return nil, err
+	}
+	var feed domain.Feed
+	if err := s.getDoc(ctx, `SELECT doc FROM feeds WHERE id = ?`, &feed, feedID); err != nil {
+		return nil, err
+	}
+	return &feed, nil
+}
+
+func (s *Repository) SaveFeed(ctx context.Context, feed domain.Feed) error {
+	if feed.SubCategory != nil && feed.SubCategory.ID.IsZero() {
+		feed.SubCategory = nil
+	}
+	doc, err := json.Marshal(feed)
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.ExecContext(ctx,
+		`INSERT INTO feeds (id, removed, doc) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET removed = excluded.removed, doc = excluded.doc`,
+		feed.ID.Hex(), feed.Removed, string(doc))
+	return err
+}
+
+func (s *Repository) SavePublisher(ctx context.Context, feedID primitive.ObjectID, publisher domain.Publisher) error {
+	doc, err := json.Marshal(publisher)
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.ExecContext(ctx,
+		`UPDATE feeds SET doc = json_set(doc, '$.publisher', json(?)) WHERE id = ?`,
+		string(doc), feedID.Hex())
+	return err
+}
+
+func (s *Repository) GetCategories(ctx context.Context) ([]rss.Category, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT doc FROM categories`)
+	if err != nil {
+		return nil, err
+	}
+	categoryList := []rss.Category{}
+	err = scanDocs(rows, func(doc []byte) error {
+		var category rss.Category
+		if err := json.Unmarshal(doc, &category); err != nil {
+			return err
+		}
+		categoryList = append(categoryList, category)
+		return nil
+	})
+	return categoryList, err
+}
+
+func (s *Repository) GetCategory(ctx context.Context, name string) (rss.Category, error) {
+	var category rss.Category
+	err := s.getDoc(ctx, `SELECT doc FROM categories WHERE name = ?`, &category, name)
+	return category, err
+}
+
+func (s *Repository) SaveCategory(ctx context.Context, category rss.Category) error {
+	doc, err := json.Marshal(category)
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.ExecContext(ctx,
+		`INSERT INTO categories (id, name, doc) VALUES (?, ?, ?) ON CONFLICT (name) DO NOTHING`,
+		category.ID.Hex(), category.Name, string(doc))
+	return err
+}
+
+func (s *Repository) GetSubCategories(ctx context.Context) ([]rss.SubCategory, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT doc FROM subcategories`)
+	if err != nil {
+		return nil, err
+	}
+	subCategoryList := []rss.SubCategory{}
+	err = scanDocs(rows, func(doc []byte) error {
+		var subCategory rss.SubCategory
+		if err := json.Unmarshal(doc, &subCategory); err != nil {
+			return err
+		}
+		subCategoryList = append(subCategoryList, subCategory)
+		return nil
+	})
+	return subCategoryList, err
+}
+
+func (s *Repository) GetSubCategory(ctx context.Context, name string) (rss.SubCategory, error) {
+	var subCategory rss.SubCategory
+	err := s.getDoc(ctx, `SELECT doc FROM subcategories WHERE name = ?`, &subCategory, name)
+	return subCategory, err
+}
+
+func (s *Repository) SaveSubCategory(ctx context.Context, subCategory rss.SubCategory) error {
+	doc, err := json.Marshal(subCategory)
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.ExecContext(ctx,
+		`INSERT INTO subcategories (id, name, doc) VALUES (?, ?, ?) ON CONFLICT (name) DO NOTHING`,
+		subCategory.ID.Hex(), subCategory.SubCategory, string(doc))
+	return err
+}
+
+func (s *Repository) GetItem(ctx context.Context, itemID string) (*rss.Item, error) {
+	var item rss.Item
+	if err := s.getDoc(ctx, `SELECT doc FROM items WHERE id = ?`, &item, itemID); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// SaveItems applies the same GUID and canonical link deduplication as the
+// Mongo store, in one transaction per call.
+func (s *Repository) SaveItems(ctx context.Context, items []rss.Item) ([]store.ItemChange, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	changes := []store.ItemChange{}
+	for _, item := range items {
+		var stored rss.Item
+		var doc string
+		err := tx.QueryRowContext(ctx,
+			`SELECT doc FROM items WHERE guid = ? OR canonical_url = ? LIMIT 1`,
+			item.GUID, item.CanonicalURL).Scan(&doc)
+		switch {
+		case err == nil:
+			if err := json.Unmarshal([]byte(doc), &stored); err != nil {
+				return nil, err
+			}
+			item = store.Merge(stored, item)
+			if store.Changed(stored, item) {
+				changes = append(changes, store.ItemChange{Item: item, Updated: true})
+			}
+		case errors.Is(err, sql.ErrNoRows):
+			item.ID = primitive.NewObjectID()
+			item.Updated = time.Now()
+			changes = append(changes, store.ItemChange{Item: item})
+		default:
+			return nil, err
+		}
+		if err := saveItem(ctx, tx, item); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func saveItem(ctx context.Context, tx *sql.Tx, item rss.Item) error {
+	doc, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO items (id, guid, canonical_url, pub_date, clicks, doc) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET guid = excluded.guid, canonical_url = excluded.canonical_url,
+			pub_date = excluded.pub_date, clicks = excluded.clicks, doc = excluded.doc`,
+		item.ID.Hex(), item.GUID, item.CanonicalURL, item.Date.Unix(), item.Clicks, string(doc))
+	return err
+}
+
+func (s *Repository) FindItems(ctx context.Context, q store.ItemQuery) ([]rss.Item, error) {
+	where, args := itemWhere(q)
+	order := ` ORDER BY pub_date DESC, id DESC`
+	if q.ByClicks {
+		order = ` ORDER BY clicks DESC, pub_date DESC, id DESC`
+	} else if q.Oldest {
+		order = ` ORDER BY pub_date ASC, id ASC`
+	}
+	query := `SELECT doc FROM items` + where + order
+	limit := q.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	query += ` LIMIT ? OFFSET ?`
+	args = append(args, limit, q.Skip)
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	items := []rss.Item{}
+	err = scanDocs(rows, func(doc []byte) error {
+		var item rss.Item
+		if err := json.Unmarshal(doc, &item); err != nil {
+			return err
+		}
+		items = append(items, item)
+		return nil
+	})
+	return items, err
+}
+
+func itemWhere(q store.ItemQuery) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	if !q.WithHidden {
+		conds = append(conds, `coalesce(json_extract(doc, '$.hidden'), 0) = 0`)
+	}
+	if q.Category != "" {
+		conds = append(conds, `(json_extract(doc, '$.category.enName') = ? OR json_extract(doc, '$.category.categoryName') = ?)`)
+		args = append(args, q.Category, q.Category)
+	}
+	if q.SubCategory != "" {
+		conds = append(conds, `(json_extract(doc, '$.subCategory.enName') = ? OR json_extract(doc, '$.subCategory.subCategory') = ?)`)
+		args = append(args, q.SubCategory, q.SubCategory)
+	}
+	if len(q.NotCategories) > 0 {
+		in := strings.TrimSuffix(strings.Repeat("?,", len(q.NotCategories)), ",")
+		conds = append(conds, `json_extract(doc, '$.category.enName') NOT IN (`+in+`)`,
+			`json_extract(doc, '$.category.categoryName') NOT IN (`+in+`)`)
+		for i := 0; i < 2; i++ {
+			for _, c := range q.NotCategories {
+				args = append(args, c)
+			}
+		}
+	}
+	if len(q.IDs) > 0 {
+		conds = append(conds, `id IN (`+strings.TrimSuffix(strings.Repeat("?,", len(q.IDs)), ",")+`)`)
+		for _, id := range q.IDs {
+			args = append(args, id.Hex())
+		}
+	}
+	if q.Source != "" {
+		conds = append(conds, `json_extract(doc, '$.rssSource') = ?`)
+		args = append(args, q.Source)
+	}
+	if !q.FeedID.IsZero() {
+		conds = append(conds, `json_extract(doc, '$.feedId') = ?`)
+		args = append(args, q.FeedID.Hex())
+	}
+	if q.Language != "" {
+		conds = append(conds, `json_extract(doc, '$.language') = ?`)
+		args = append(args, q.Language)
+	}
+	if !q.ClusterID.IsZero() {
+		conds = append(conds, `json_extract(doc, '$.clusterId') = ?`)
+		args = append(args, q.ClusterID.Hex())
+	}
+	if q.Representatives {
+		conds = append(conds, `(coalesce(json_extract(doc, '$.clusterId'), ?) = ? OR json_extract(doc, '$.representative') = 1)`)
+		args = append(args, primitive.NilObjectID.Hex(), primitive.NilObjectID.Hex())
+	}
+	if !q.After.IsZero() {
+		conds = append(conds, `pub_date >= ?`)
+		args = append(args, q.After.Unix())
+	}
+	if !q.Before.IsZero() {
+		conds = append(conds, `pub_date < ?`)
+		args = append(args, q.Before.Unix())
+	}
+	if q.ClicksBelow > 0 {
+		conds = append(conds, `clicks < ?`)
+		args = append(args, q.ClicksBelow)
+	}
+	if q.ClicksAtLeast > 0 {
+		conds = append(conds, `clicks >= ?`)
+		args = append(args, q.ClicksAtLeast)
+	}
+	if q.Text != "" {
+		text := strings.ToLower(q.Text)
+		conds = append(conds, `(instr(fold(json_extract(doc, '$.rssTitle')), ?) > 0 OR instr(fold(json_extract(doc, '$.rssDesc')), ?) > 0)`)
+		args = append(args, text, text)
+	}
+	if c := q.Cursor; c != nil {
+		next := "<"
+		if q.Oldest && !q.ByClicks {
+			next = ">"
+		}
+		cond := `(pub_date ` + next + ` ? OR pub_date = ? AND id ` + next + ` ?)`
+		if q.ByClicks {
+			cond = `(clicks < ? OR clicks = ? AND ` + cond + `)`
+			args = append(args, c.Clicks, c.Clicks)
+		}
+		conds = append(conds, cond)
+		args = append(args, c.Date.Unix(), c.Date.Unix(), c.ID.Hex())
+	}
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// fold lower cases text the way Go does, as SQLite's lower() and LIKE only
+// fold ASCII letters.
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction("fold", 1, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		switch v := args[0].(type) {
+		case string:
+			return strings.ToLower(v), nil
+		case []byte:
+			return strings.ToLower(string(v)), nil
+		}
+		return args[0], nil
+	})
+}
+
+func (s *Repository) DeleteItems(ctx context.Context, ids []primitive.ObjectID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id.Hex()
+	}
+	in := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM items WHERE id IN (`+in+`)`, args...)
+	return err
+}
+
+func (s *Repository) ArchiveItems(ctx context.Context, items []rss.Item) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, item := range items {
+		doc, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO items_archive (id, doc) VALUES (?, ?) ON CONFLICT (id) DO UPDATE SET doc = excluded.doc`,
+			item.ID.Hex(), string(doc))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *Repository) SetClusters(ctx context.Context, updates []store.ClusterUpdate) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, u := range updates {
+		_, err := tx.ExecContext(ctx,
+			`UPDATE items SET doc = json_set(doc, '$.clusterId', ?, '$.representative', json(?)) WHERE id = ?`,
+			u.ClusterID.Hex(), boolJSON(u.Representative && !u.ClusterID.IsZero()), u.ItemID.Hex())
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *Repository) BackfillFeedID(ctx context.Context, source string, feedID primitive.ObjectID) (int, error) {
+	result, err := s.DB.ExecContext(ctx,
+		`UPDATE items SET doc = json_set(doc, '$.feedId', ?)
+		WHERE json_extract(doc, '$.rssSource') = ?
+		AND coalesce(json_extract(doc, '$.feedId'), '') IN ('', ?)`,
+		feedID.Hex(), source, primitive.NilObjectID.Hex())
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+func boolJSON(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+func (s *Repository) getDoc(ctx context.Context, query string, v interface{}, args ...interface{}) error {
+	var doc string
+	err := s.DB.QueryRowContext(ctx, query, args...).Scan(&doc)
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(doc), v)
+}
+
+func scanDocs(rows *sql.Rows, fn func(doc []byte) error) error {
+	defer rows.Close()
+	for rows.Next() {
+		var doc []byte
+		if err := rows.Scan(&doc); err != nil {
+			return err
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
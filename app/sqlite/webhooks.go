@@ -0,0 +1,116 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/domain"
+	"github.com/jelinden/rssfetcher/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Repository) GetWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT doc FROM webhooks ORDER BY created`)
+	if err != nil {
+		return nil, err
+	}
+	webhooks := []domain.Webhook{}
+	err = scanDocs(rows, func(doc []byte) error {
+		var webhook domain.Webhook
+		if err := json.Unmarshal(doc, &webhook); err != nil {
+			return err
+		}
+		webhooks = append(webhooks, webhook)
+		return nil
+	})
+	return webhooks, err
+}
+
+func (s *Repository) GetWebhook(ctx context.Context, id primitive.ObjectID) (*domain.Webhook, error) {
+	var webhook domain.Webhook
+	if err := s.getDoc(ctx, `SELECT doc FROM webhooks WHERE id = ?`, &webhook, id.Hex()); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (s *Repository) SaveWebhook(ctx context.Context, webhook domain.Webhook) error {
+	doc, err := json.Marshal(webhook)
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.ExecContext(ctx,
+		`INSERT INTO webhooks (id, created, doc) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET created = excluded.created, doc = excluded.doc`,
+		webhook.ID.Hex(), webhook.Created.UnixNano(), string(doc))
+	return err
+}
+
+func (s *Repository) DeleteWebhook(ctx context.Context, id primitive.ObjectID) error {
+	return s.delete(ctx, "webhooks", id)
+}
+
+func (s *Repository) SaveDelivery(ctx context.Context, delivery domain.Delivery) error {
+	doc, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.ExecContext(ctx,
+		`INSERT INTO deliveries (id, webhook_id, status, created, doc) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET webhook_id = excluded.webhook_id, status = excluded.status,
+			created = excluded.created, doc = excluded.doc`,
+		delivery.ID.Hex(), delivery.WebhookID.Hex(), delivery.Status, delivery.Created.UnixNano(), string(doc))
+	return err
+}
+
+func (s *Repository) GetDelivery(ctx context.Context, id primitive.ObjectID) (*domain.Delivery, error) {
+	var delivery domain.Delivery
+	if err := s.getDoc(ctx, `SELECT doc FROM deliveries WHERE id = ?`, &delivery, id.Hex()); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (s *Repository) FindDeliveries(ctx context.Context, q store.DeliveryQuery) ([]domain.Delivery, error) {
+	query := `SELECT doc FROM deliveries`
+	var conds []string
+	var args []interface{}
+	if !q.WebhookID.IsZero() {
+		conds = append(conds, `webhook_id = ?`)
+		args = append(args, q.WebhookID.Hex())
+	}
+	if q.Status != "" {
+		conds = append(conds, `status = ?`)
+		args = append(args, q.Status)
+	}
+	if len(conds) > 0 {
+		query += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	query += ` ORDER BY created DESC LIMIT ?`
+	args = append(args, limit)
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	deliveries := []domain.Delivery{}
+	err = scanDocs(rows, func(doc []byte) error {
+		var delivery domain.Delivery
+		if err := json.Unmarshal(doc, &delivery); err != nil {
+			return err
+		}
+		deliveries = append(deliveries, delivery)
+		return nil
+	})
+	return deliveries, err
+}
+
+func (s *Repository) DeleteDeliveries(ctx context.Context, before time.Time) error {
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM deliveries WHERE created < ?`, before.UnixNano())
+	return err
+}
@@ -0,0 +1,218 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/domain"
+	"github.com/jelinden/rssfetcher/app/rss"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrDuplicate = errors.New("name already in use")
+)
+
+// Store is the persistence used by the admin handlers and the fetch loop.
+type Store interface {
+	// GetFeeds returns all feeds, or only the ones not removed when
+	// activeOnly is set.
+	GetFeeds(ctx context.Context, activeOnly bool) ([]domain.Feed, error)
+	GetFeed(ctx context.Context, id string) (*domain.Feed, error)
+	// SaveFeed inserts the feed or replaces the one with the same ID.
+	SaveFeed(ctx context.Context, feed domain.Feed) error
+	SavePublisher(ctx context.Context, feedID primitive.ObjectID, publisher domain.Publisher) error
+
+	GetCategories(ctx context.Context) ([]rss.Category, error)
+	GetCategory(ctx context.Context, name string) (rss.Category, error)
+	GetCategoryByID(ctx context.Context, id primitive.ObjectID) (rss.Category, error)
+	// SaveCategory inserts the category unless one with the same name exists.
+	SaveCategory(ctx context.Context, category rss.Category) error
+	// UpdateCategory replaces the category with the same ID, failing with
+	// ErrDuplicate when another category has its name.
+	UpdateCategory(ctx context.Context, category rss.Category) error
+	// CountCategoryRefs counts the feeds and items embedding the category.
+	CountCategoryRefs(ctx context.Context, id primitive.ObjectID) (int, error)
+	// ReassignCategory replaces the embedded category with ID from by to in
+	// at most limit feeds and items, skipping copies already equal to it. It
+	// returns how many were changed, zero once none are left.
+	ReassignCategory(ctx context.Context, from primitive.ObjectID, to rss.Category, limit int) (int, error)
+	// DeleteCategory removes the category, leaving feeds and items that
+	// embed it untouched.
+	DeleteCategory(ctx context.Context, id primitive.ObjectID) error
+
+	GetSubCategories(ctx context.Context) ([]rss.SubCategory, error)
+	GetSubCategory(ctx context.Context, name string) (rss.SubCategory, error)
+	GetSubCategoryByID(ctx context.Context, id primitive.ObjectID) (rss.SubCategory, error)
+	// SaveSubCategory inserts the subcategory unless one with the same name
+	// exists.
+	SaveSubCategory(ctx context.Context, subCategory rss.SubCategory) error
+	UpdateSubCategory(ctx context.Context, subCategory rss.SubCategory) error
+	CountSubCategoryRefs(ctx context.Context, id primitive.ObjectID) (int, error)
+	ReassignSubCategory(ctx context.Context, from primitive.ObjectID, to rss.SubCategory, limit int) (int, error)
+	DeleteSubCategory(ctx context.Context, id primitive.ObjectID) error
+
+	GetItem(ctx context.Context, id string) (*rss.Item, error)
+	// SaveItems stores news items. An item matching a stored one on GUID or
+	// canonical link updates it, keeping the stored ID, GUID, clicks,
+	// publication date, cluster and hidden state. Other items are inserted
+	// with a new ID. It returns the inserted items and the updated ones that
+	// Changed.
+	SaveItems(ctx context.Context, items []rss.Item) ([]ItemChange, error)
+	FindItems(ctx context.Context, q ItemQuery) ([]rss.Item, error)
+	DeleteItems(ctx context.Context, ids []primitive.ObjectID) error
+	// ArchiveItems copies items to the archive, replacing earlier copies.
+	ArchiveItems(ctx context.Context, items []rss.Item) error
+	SetClusters(ctx context.Context, updates []ClusterUpdate) error
+	// HideItems hides the items with the given IDs for the block rule.
+	HideItems(ctx context.Context, ids []primitive.ObjectID, rule domain.BlockRule) error
+	// UnhideItems shows the items hidden for the block rule again and
+	// returns how many.
+	UnhideItems(ctx context.Context, ruleID primitive.ObjectID) (int, error)
+	// BackfillFeedID sets the feed ID of the items from source that have
+	// none and returns how many were changed.
+	BackfillFeedID(ctx context.Context, source string, feedID primitive.ObjectID) (int, error)
+
+	// AddClick increments the clicks of an item and its counter for the
+	// hour of at, failing with ErrNotFound for unknown items.
+	AddClick(ctx context.Context, itemID primitive.ObjectID, at time.Time) error
+	// ClickCounts returns the hourly click counters from the hour of since on.
+	ClickCounts(ctx context.Context, since time.Time) ([]ClickCount, error)
+	// DeleteClickCounts removes the counters of hours before the given time.
+	DeleteClickCounts(ctx context.Context, before time.Time) error
+
+	GetWebhooks(ctx context.Context) ([]domain.Webhook, error)
+	// GetWebhook fails with ErrNotFound for unknown IDs, as do GetDelivery
+	// and DeleteWebhook.
+	GetWebhook(ctx context.Context, id primitive.ObjectID) (*domain.Webhook, error)
+	// SaveWebhook inserts the webhook or replaces the one with its ID.
+	SaveWebhook(ctx context.Context, webhook domain.Webhook) error
+	DeleteWebhook(ctx context.Context, id primitive.ObjectID) error
+	// SaveDelivery inserts the delivery or replaces the one with its ID.
+	SaveDelivery(ctx context.Context, delivery domain.Delivery) error
+	GetDelivery(ctx context.Context, id primitive.ObjectID) (*domain.Delivery, error)
+	// FindDeliveries returns the matching deliveries, latest created first.
+	FindDeliveries(ctx context.Context, q DeliveryQuery) ([]domain.Delivery, error)
+	// DeleteDeliveries removes the deliveries created before the given time.
+	DeleteDeliveries(ctx context.Context, before time.Time) error
+
+	// GetBlockRules returns the global blocklist, oldest first.
+	GetBlockRules(ctx context.Context) ([]domain.BlockRule, error)
+	// SaveBlockRule inserts the rule or replaces the one with its ID.
+	SaveBlockRule(ctx context.Context, rule domain.BlockRule) error
+	// DeleteBlockRule fails with ErrNotFound for unknown IDs.
+	DeleteBlockRule(ctx context.Context, id primitive.ObjectID) error
+
+	Close(ctx context.Context) error
+}
+
+// ItemQuery selects stored news items. Zero values match everything.
+type ItemQuery struct {
+	// Category matches the category's enName or name.
+	Category string
+	// SubCategory matches the subcategory's enName or name.
+	SubCategory string
+	// NotCategories excludes items whose category enName or name is listed.
+	NotCategories []string
+	Source        string
+	FeedID        primitive.ObjectID
+	// IDs matches the items with the listed IDs when it is not empty.
+	IDs       []primitive.ObjectID
+	Language  string
+	ClusterID primitive.ObjectID
+	// Representatives leaves out the items of a story cluster other than
+	// its representative.
+	Representatives bool
+	// After and Before match items published at or after and before the
+	// given times.
+	After  time.Time
+	Before time.Time
+	// ClicksBelow matches items with fewer clicks when it is positive,
+	// ClicksAtLeast items with at least as many.
+	ClicksBelow   int
+	ClicksAtLeast int
+	// Text matches items whose title or description contains it, ignoring
+	// case.
+	Text string
+	// WithHidden also matches the items hidden by block rules.
+	WithHidden bool
+	// Oldest sorts by publication date ascending instead of newest first.
+	Oldest bool
+	// ByClicks sorts by clicks, most first, then newest first. Oldest is
+	// ignored.
+	ByClicks bool
+	// Cursor continues the listing after the item it was made from.
+	Cursor *ItemCursor
+	Skip   int
+	Limit  int
+}
+
+// ItemCursor is the position of an item in a sorted listing.
+type ItemCursor struct {
+	Date   time.Time
+	Clicks int
+	ID     primitive.ObjectID
+}
+
+// CursorOf returns the cursor continuing after item.
+func CursorOf(item rss.Item) *ItemCursor {
+	return &ItemCursor{Date: item.Date, Clicks: item.Clicks, ID: item.ID}
+}
+
+// ItemChange is an item as stored by SaveItems.
+type ItemChange struct {
+	Item    rss.Item
+	Updated bool
+}
+
+// Merge returns item updating stored, with the fields SaveItems keeps.
+// Updated is set to now when the update is Changed.
+func Merge(stored, item rss.Item) rss.Item {
+	item.ID = stored.ID
+	item.GUID = stored.GUID
+	if stored.Date.Unix() > 0 {
+		item.Date = stored.Date
+	}
+	item.Clicks = stored.Clicks
+	item.ClusterID = stored.ClusterID
+	item.Representative = stored.Representative
+	item.Hidden = stored.Hidden
+	item.HiddenBy = stored.HiddenBy
+	item.HiddenReason = stored.HiddenReason
+	item.Updated = stored.Updated
+	if Changed(stored, item) {
+		item.Updated = time.Now()
+	}
+	return item
+}
+
+// Changed reports whether an update changes what readers see of an item.
+func Changed(stored, item rss.Item) bool {
+	return stored.Title != item.Title || stored.Content != item.Content ||
+		stored.Link != item.Link || stored.Enclosure != item.Enclosure
+}
+
+// ClickCount is the number of clicks an item got in the hour starting at
+// Hour.
+type ClickCount struct {
+	ItemID primitive.ObjectID `json:"itemId" bson:"itemId"`
+	Hour   time.Time          `json:"hour" bson:"hour"`
+	Clicks int                `json:"clicks" bson:"clicks"`
+}
+
+// DeliveryQuery selects webhook deliveries. Zero values match all.
+type DeliveryQuery struct {
+	WebhookID primitive.ObjectID
+	Status    string
+	Limit     int
+}
+
+// ClusterUpdate assigns an item to a story cluster. A zero ClusterID removes
+// the item from its cluster.
+type ClusterUpdate struct {
+	ItemID         primitive.ObjectID
+	ClusterID      primitive.ObjectID
+	Representative bool
+}
@@ -0,0 +1,509 @@
+// Package storetest is the contract every store.Store backend is tested
+// against.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/domain"
+	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Run runs the contract against stores made by open, a new empty one for
+// each test.
+func Run(t *testing.T, open func(t *testing.T) store.Store) {
+	tests := map[string]func(*testing.T, store.Store){
+		"Feeds":               testFeeds,
+		"Categories":          testCategories,
+		"SubCategories":       testSubCategories,
+		"SaveItemsDedup":      testSaveItemsDedup,
+		"SaveItemsKeepsState": testSaveItemsKeepsState,
+		"SaveItemsUpdated":    testSaveItemsUpdated,
+		"FindItemsFilters":    testFindItemsFilters,
+		"FindItemsCursor":     testFindItemsCursor,
+		"Clicks":              testClicks,
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test(t, open(t))
+		})
+	}
+}
+
+// now is truncated to the second, the precision every backend keeps.
+func now() time.Time {
+	return time.Now().Truncate(time.Second).UTC()
+}
+
+func item(guid string, date time.Time) rss.Item {
+	link := "https://example.com/" + guid
+	return rss.Item{
+		Title:        "Title " + guid,
+		Content:      "Content " + guid,
+		Link:         link,
+		GUID:         guid,
+		CanonicalURL: rss.CanonicalURL(link),
+		Date:         date,
+		Language:     "fi",
+		Source:       "Example",
+	}
+}
+
+// saveItems stores items and returns them as stored, keyed by GUID.
+func saveItems(t *testing.T, s store.Store, items ...rss.Item) map[string]rss.Item {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := s.SaveItems(ctx, items); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := s.FindItems(ctx, store.ItemQuery{WithHidden: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byGUID := make(map[string]rss.Item)
+	for _, item := range stored {
+		byGUID[item.GUID] = item
+	}
+	return byGUID
+}
+
+func guids(items []rss.Item) []string {
+	list := make([]string, len(items))
+	for i, item := range items {
+		list[i] = item.GUID
+	}
+	return list
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func testFeeds(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	feed := domain.Feed{
+		ID:       primitive.NewObjectID(),
+		Name:     "Example",
+		URL:      "https://example.com/rss",
+		Language: "fi",
+		Category: rss.Category{ID: primitive.NewObjectID(), Name: "Urheilu", EnName: "sports"},
+		Exclude:  []domain.FilterRule{{Field: "title", Pattern: "horoscope"}},
+	}
+	removed := domain.Feed{ID: primitive.NewObjectID(), Name: "Gone", URL: "https://example.com/gone", Removed: true}
+	for _, f := range []domain.Feed{feed, removed} {
+		if err := s.SaveFeed(ctx, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := s.GetFeed(ctx, feed.ID.Hex())
+	if err != nil || got.Name != feed.Name || got.Category.EnName != "sports" || len(got.Exclude) != 1 {
+		t.Fatal("get", got, err)
+	}
+	if all, _ := s.GetFeeds(ctx, false); len(all) != 2 {
+		t.Error("all feeds", all)
+	}
+	if active, _ := s.GetFeeds(ctx, true); len(active) != 1 || active[0].ID != feed.ID {
+		t.Error("active feeds", active)
+	}
+
+	feed.Name = "Renamed"
+	if err := s.SaveFeed(ctx, feed); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.GetFeed(ctx, feed.ID.Hex()); got == nil || got.Name != "Renamed" {
+		t.Error("replaced", got)
+	}
+	if err := s.SavePublisher(ctx, feed.ID, domain.Publisher{Title: "Publisher"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.GetFeed(ctx, feed.ID.Hex()); got == nil || got.Publisher == nil || got.Publisher.Title != "Publisher" {
+		t.Error("publisher", got)
+	}
+	if _, err := s.GetFeed(ctx, primitive.NewObjectID().Hex()); !errors.Is(err, store.ErrNotFound) {
+		t.Error("unknown feed", err)
+	}
+}
+
+func testCategories(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	sports := rss.Category{ID: primitive.NewObjectID(), Name: "Urheilu", EnName: "sports"}
+	economy := rss.Category{ID: primitive.NewObjectID(), Name: "Talous", EnName: "economy"}
+	for _, c := range []rss.Category{sports, economy, {ID: primitive.NewObjectID(), Name: "Urheilu"}} {
+		if err := s.SaveCategory(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if all, _ := s.GetCategories(ctx); len(all) != 2 {
+		t.Error("a category with a used name was saved", all)
+	}
+	if got, err := s.GetCategory(ctx, "Urheilu"); err != nil || got.ID != sports.ID {
+		t.Error("by name", got, err)
+	}
+	if got, err := s.GetCategoryByID(ctx, economy.ID); err != nil || got.EnName != "economy" {
+		t.Error("by id", got, err)
+	}
+	if _, err := s.GetCategory(ctx, "nope"); !errors.Is(err, store.ErrNotFound) {
+		t.Error("unknown name", err)
+	}
+	if _, err := s.GetCategoryByID(ctx, primitive.NewObjectID()); !errors.Is(err, store.ErrNotFound) {
+		t.Error("unknown id", err)
+	}
+
+	sports.EnName = "sport"
+	if err := s.UpdateCategory(ctx, sports); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.GetCategoryByID(ctx, sports.ID); got.EnName != "sport" {
+		t.Error("updated", got)
+	}
+	economy.Name = "Urheilu"
+	if err := s.UpdateCategory(ctx, economy); !errors.Is(err, store.ErrDuplicate) {
+		t.Error("duplicate name", err)
+	}
+
+	feed := domain.Feed{ID: primitive.NewObjectID(), Name: "Example", Category: sports}
+	if err := s.SaveFeed(ctx, feed); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.CountCategoryRefs(ctx, sports.ID); err != nil || n != 1 {
+		t.Error("refs", n, err)
+	}
+	target := rss.Category{ID: primitive.NewObjectID(), Name: "Muut", EnName: "other"}
+	if n, err := s.ReassignCategory(ctx, sports.ID, target, 10); err != nil || n != 1 {
+		t.Error("reassigned", n, err)
+	}
+	if got, _ := s.GetFeed(ctx, feed.ID.Hex()); got == nil || got.Category.ID != target.ID {
+		t.Error("feed category", got)
+	}
+	if err := s.DeleteCategory(ctx, sports.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetCategoryByID(ctx, sports.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Error("deleted", err)
+	}
+}
+
+func testSubCategories(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	parent := primitive.NewObjectID()
+	football := rss.SubCategory{ID: primitive.NewObjectID(), SubCategory: "Jalkapallo", EnName: "football", ParentID: parent}
+	hockey := rss.SubCategory{ID: primitive.NewObjectID(), SubCategory: "Jääkiekko", EnName: "hockey", ParentID: parent}
+	for _, c := range []rss.SubCategory{football, hockey} {
+		if err := s.SaveSubCategory(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if all, _ := s.GetSubCategories(ctx); len(all) != 2 {
+		t.Error("all", all)
+	}
+	if got, err := s.GetSubCategory(ctx, "Jääkiekko"); err != nil || got.ID != hockey.ID || got.ParentID != parent {
+		t.Error("by name", got, err)
+	}
+	football.EnName = "soccer"
+	if err := s.UpdateSubCategory(ctx, football); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.GetSubCategoryByID(ctx, football.ID); err != nil || got.EnName != "soccer" {
+		t.Error("updated", got, err)
+	}
+	hockey.SubCategory = "Jalkapallo"
+	if err := s.UpdateSubCategory(ctx, hockey); !errors.Is(err, store.ErrDuplicate) {
+		t.Error("duplicate name", err)
+	}
+	if err := s.DeleteSubCategory(ctx, football.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetSubCategoryByID(ctx, football.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Error("deleted", err)
+	}
+}
+
+func testSaveItemsDedup(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	date := now().Add(-time.Hour)
+	changes, err := s.SaveItems(ctx, []rss.Item{item("a", date), item("b", date)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].Updated || changes[1].Updated || changes[0].Item.ID.IsZero() {
+		t.Fatal("inserts", changes)
+	}
+
+	// The same GUID, and another GUID with the same canonical link, update
+	// the stored items. Unchanged items are not reported.
+	a := item("a", date)
+	a.Title = "Changed"
+	b := item("b2", date)
+	b.Link, b.CanonicalURL = "https://example.com/b?utm_source=x", rss.CanonicalURL("https://example.com/b")
+	changes, err = s.SaveItems(ctx, []rss.Item{a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || !changes[0].Updated || changes[0].Item.Title != "Changed" || !changes[1].Updated {
+		t.Error("updates", changes)
+	}
+	changes, err = s.SaveItems(ctx, []rss.Item{a})
+	if err != nil || len(changes) != 0 {
+		t.Error("unchanged", changes, err)
+	}
+	items, _ := s.FindItems(ctx, store.ItemQuery{})
+	if len(items) != 2 {
+		t.Fatal("stored", guids(items))
+	}
+	stored := saveItems(t, s)
+	if stored["a"].Title != "Changed" || stored["b"].Link != b.Link {
+		t.Error("stored", stored)
+	}
+	if got, err := s.GetItem(ctx, stored["a"].ID.Hex()); err != nil || got.GUID != "a" {
+		t.Error("get", got, err)
+	}
+	if _, err := s.GetItem(ctx, primitive.NewObjectID().Hex()); !errors.Is(err, store.ErrNotFound) {
+		t.Error("unknown item", err)
+	}
+}
+
+func testSaveItemsKeepsState(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	date := now().Add(-time.Hour)
+	undated := item("undated", time.Time{})
+	stored := saveItems(t, s, item("a", date), undated)
+	a := stored["a"]
+	if err := s.AddClick(ctx, a.ID, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	cluster := primitive.NewObjectID()
+	if err := s.SetClusters(ctx, []store.ClusterUpdate{{ItemID: a.ID, ClusterID: cluster, Representative: true}}); err != nil {
+		t.Fatal(err)
+	}
+	rule := domain.BlockRule{ID: primitive.NewObjectID(), Reason: "takedown"}
+	if err := s.HideItems(ctx, []primitive.ObjectID{a.ID}, rule); err != nil {
+		t.Fatal(err)
+	}
+
+	refetched := item("a", date.Add(time.Hour))
+	refetched.Title = "Refetched"
+	redated := item("undated", date)
+	stored = saveItems(t, s, refetched, redated)
+	got := stored["a"]
+	if got.ID != a.ID || got.Title != "Refetched" {
+		t.Error("updated", got)
+	}
+	if !got.Date.Equal(date) {
+		t.Error("date replaced", got.Date)
+	}
+	if got.Clicks != 1 {
+		t.Error("clicks", got.Clicks)
+	}
+	if got.ClusterID != cluster || !got.Representative {
+		t.Error("cluster", got.ClusterID, got.Representative)
+	}
+	if !got.Hidden || got.HiddenBy != rule.ID || got.HiddenReason != "takedown" {
+		t.Error("hidden", got.Hidden, got.HiddenBy, got.HiddenReason)
+	}
+	if !stored["undated"].Date.Equal(date) {
+		t.Error("unset date kept", stored["undated"].Date)
+	}
+
+	if n, err := s.UnhideItems(ctx, rule.ID); err != nil || n != 1 {
+		t.Error("unhide", n, err)
+	}
+	if items, _ := s.FindItems(ctx, store.ItemQuery{}); len(items) != 2 {
+		t.Error("visible after unhide", guids(items))
+	}
+}
+
+func testSaveItemsUpdated(t *testing.T, s store.Store) {
+	start := now()
+	a := item("a", start)
+	first := saveItems(t, s, a)["a"].Updated
+	if first.Before(start) {
+		t.Fatal("updated on insert", first)
+	}
+	// Each backend keeps at least milliseconds.
+	time.Sleep(10 * time.Millisecond)
+	if got := saveItems(t, s, a)["a"].Updated; !got.Equal(first) {
+		t.Error("updated without changes", got, first)
+	}
+	a.Title = "Changed"
+	if got := saveItems(t, s, a)["a"].Updated; !got.After(first) {
+		t.Error("updated on change", got, first)
+	}
+}
+
+func testFindItemsFilters(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	base := now().Add(-10 * time.Hour)
+	sports := rss.Category{ID: primitive.NewObjectID(), Name: "Urheilu", EnName: "sports"}
+	economy := rss.Category{ID: primitive.NewObjectID(), Name: "Talous", EnName: "economy"}
+	football := &rss.SubCategory{ID: primitive.NewObjectID(), SubCategory: "Jalkapallo", EnName: "football"}
+	feedID := primitive.NewObjectID()
+
+	a := item("a", base)
+	a.Category, a.SubCategory, a.FeedID = sports, football, feedID
+	a.Title = "Äänestys alkaa"
+	b := item("b", base.Add(time.Hour))
+	b.Category, b.Language, b.Source = economy, "en", "Other"
+	c := item("c", base.Add(2*time.Hour))
+	c.Category = sports
+	hidden := item("hidden", base.Add(3*time.Hour))
+	hidden.Category = sports
+	stored := saveItems(t, s, a, b, c, hidden)
+	if err := s.AddClick(ctx, stored["c"].ID, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.HideItems(ctx, []primitive.ObjectID{stored["hidden"].ID}, domain.BlockRule{ID: primitive.NewObjectID()}); err != nil {
+		t.Fatal(err)
+	}
+	cluster := primitive.NewObjectID()
+	if err := s.SetClusters(ctx, []store.ClusterUpdate{
+		{ItemID: stored["b"].ID, ClusterID: cluster},
+		{ItemID: stored["c"].ID, ClusterID: cluster, Representative: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, test := range map[string]struct {
+		q    store.ItemQuery
+		want []string
+	}{
+		"all":              {store.ItemQuery{}, []string{"c", "b", "a"}},
+		"with hidden":      {store.ItemQuery{WithHidden: true}, []string{"hidden", "c", "b", "a"}},
+		"oldest":           {store.ItemQuery{Oldest: true}, []string{"a", "b", "c"}},
+		"category enName":  {store.ItemQuery{Category: "sports"}, []string{"c", "a"}},
+		"category name":    {store.ItemQuery{Category: "Talous"}, []string{"b"}},
+		"subcategory":      {store.ItemQuery{SubCategory: "football"}, []string{"a"}},
+		"not categories":   {store.ItemQuery{NotCategories: []string{"sports"}}, []string{"b"}},
+		"source":           {store.ItemQuery{Source: "Other"}, []string{"b"}},
+		"feed":             {store.ItemQuery{FeedID: feedID}, []string{"a"}},
+		"ids":              {store.ItemQuery{IDs: []primitive.ObjectID{stored["a"].ID, stored["c"].ID}}, []string{"c", "a"}},
+		"language":         {store.ItemQuery{Language: "en"}, []string{"b"}},
+		"cluster":          {store.ItemQuery{ClusterID: cluster}, []string{"c", "b"}},
+		"representatives":  {store.ItemQuery{Representatives: true}, []string{"c", "a"}},
+		"after":            {store.ItemQuery{After: base.Add(time.Hour)}, []string{"c", "b"}},
+		"before":           {store.ItemQuery{Before: base.Add(time.Hour)}, []string{"a"}},
+		"clicks below":     {store.ItemQuery{ClicksBelow: 1}, []string{"b", "a"}},
+		"clicks at least":  {store.ItemQuery{ClicksAtLeast: 1}, []string{"c"}},
+		"by clicks":        {store.ItemQuery{ByClicks: true}, []string{"c", "b", "a"}},
+		"text":             {store.ItemQuery{Text: "content B"}, []string{"b"}},
+		"text folds case":  {store.ItemQuery{Text: "ÄÄNESTYS"}, []string{"a"}},
+		"skip and limit":   {store.ItemQuery{Skip: 1, Limit: 1}, []string{"b"}},
+		"combined filters": {store.ItemQuery{Category: "sports", Language: "fi", After: base.Add(time.Minute)}, []string{"c"}},
+	} {
+		items, err := s.FindItems(ctx, test.q)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if got := guids(items); !equal(got, test.want) {
+			t.Error(name, got, "want", test.want)
+		}
+	}
+}
+
+func testFindItemsCursor(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	base := now().Add(-time.Hour)
+	var items []rss.Item
+	for i, guid := range []string{"a", "b", "c", "d", "e"} {
+		// b and c share a date, so the ID breaks the tie.
+		date := base.Add(time.Duration(i) * time.Minute)
+		if guid == "c" {
+			date = base.Add(time.Minute)
+		}
+		items = append(items, item(guid, date))
+	}
+	stored := saveItems(t, s, items...)
+	for _, guid := range []string{"a", "a", "d"} {
+		if err := s.AddClick(ctx, stored[guid].ID, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, q := range map[string]store.ItemQuery{
+		"newest":    {},
+		"oldest":    {Oldest: true},
+		"by clicks": {ByClicks: true},
+	} {
+		all, err := s.FindItems(ctx, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paged []rss.Item
+		q.Limit = 2
+		for {
+			page, err := s.FindItems(ctx, q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			paged = append(paged, page...)
+			if len(page) < q.Limit {
+				break
+			}
+			q.Cursor = store.CursorOf(page[len(page)-1])
+		}
+		if !equal(guids(paged), guids(all)) || len(all) != 5 {
+			t.Error(name, "paged", guids(paged), "all", guids(all))
+		}
+	}
+}
+
+func testClicks(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	stored := saveItems(t, s, item("a", now()), item("b", now()))
+	hour := now().Truncate(time.Hour)
+	clicks := []struct {
+		guid string
+		at   time.Time
+	}{
+		{"a", hour.Add(-2 * time.Hour)},
+		{"a", hour.Add(10 * time.Minute)},
+		{"a", hour.Add(20 * time.Minute)},
+		{"b", hour.Add(30 * time.Minute)},
+	}
+	for _, c := range clicks {
+		if err := s.AddClick(ctx, stored[c.guid].ID, c.at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.AddClick(ctx, primitive.NewObjectID(), hour); !errors.Is(err, store.ErrNotFound) {
+		t.Error("unknown item", err)
+	}
+	if got, _ := s.GetItem(ctx, stored["a"].ID.Hex()); got == nil || got.Clicks != 3 {
+		t.Error("item clicks", got)
+	}
+
+	counts, err := s.ClickCounts(ctx, hour.Add(-3*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := make(map[primitive.ObjectID]int)
+	for _, c := range counts {
+		total[c.ItemID] += c.Clicks
+		if !c.Hour.Equal(c.Hour.Truncate(time.Hour)) {
+			t.Error("hour not truncated", c.Hour)
+		}
+	}
+	if len(counts) != 3 || total[stored["a"].ID] != 3 || total[stored["b"].ID] != 1 {
+		t.Error("counts", counts)
+	}
+	if recent, _ := s.ClickCounts(ctx, hour); len(recent) != 2 {
+		t.Error("counts since the hour", recent)
+	}
+	if err := s.DeleteClickCounts(ctx, hour); err != nil {
+		t.Fatal(err)
+	}
+	if left, _ := s.ClickCounts(ctx, hour.Add(-3*time.Hour)); len(left) != 2 {
+		t.Error("counts left", left)
+	}
+}
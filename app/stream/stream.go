@@ -0,0 +1,117 @@
+package stream
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/store"
+)
+
+const (
+	Insert = "insert"
+	Update = "update"
+)
+
+// Event is an item inserted or changed by the fetcher.
+type Event struct {
+	ID   uint64
+	Type string
+	Item rss.Item
+}
+
+// Hub keeps the latest Size events in a ring buffer and wakes up the
+// subscribers when new ones are published.
+type Hub struct {
+	Size int
+
+	mu          sync.Mutex
+	events      []Event
+	next        int
+	lastID      uint64
+	subscribers map[chan struct{}]struct{}
+}
+
+// New makes a hub whose event IDs start from the current time, so that IDs
+// seen before a restart are older than any event of this process.
+func New(size int) *Hub {
+	if size < 1 {
+		size = 1
+	}
+	return &Hub{
+		Size:        size,
+		events:      make([]Event, 0, size),
+		lastID:      uint64(time.Now().UnixNano()),
+		subscribers: make(map[chan struct{}]struct{}),
+	}
+}
+
+// Publish adds events for the changes and wakes up the subscribers. Items
+// hidden by a block rule are left out.
+func (h *Hub) Publish(changes []store.ItemChange) {
+	if len(changes) == 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, change := range changes {
+		if change.Item.Hidden {
+			continue
+		}
+		h.lastID++
+		event := Event{ID: h.lastID, Type: Insert, Item: change.Item}
+		if change.Updated {
+			event.Type = Update
+		}
+		if len(h.events) < h.Size {
+			h.events = append(h.events, event)
+		} else {
+			h.events[h.next] = event
+			h.next = (h.next + 1) % h.Size
+		}
+	}
+	for c := range h.subscribers {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Since returns the buffered events after the one with the given ID, oldest
+// first. IDs older than the buffer get all of it, unknown newer ones none.
+func (h *Hub) Since(id uint64) []Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var events []Event
+	for i := range h.events {
+		event := h.events[(h.next+i)%len(h.events)]
+		if event.ID > id {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// LastID is the ID of the latest event.
+func (h *Hub) LastID() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lastID
+}
+
+// Subscribe returns a channel signalled after each Publish, until
+// Unsubscribe.
+func (h *Hub) Subscribe() chan struct{} {
+	c := make(chan struct{}, 1)
+	h.mu.Lock()
+	h.subscribers[c] = struct{}{}
+	h.mu.Unlock()
+	return c
+}
+
+func (h *Hub) Unsubscribe(c chan struct{}) {
+	h.mu.Lock()
+	delete(h.subscribers, c)
+	h.mu.Unlock()
+}
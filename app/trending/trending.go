@@ -0,0 +1,182 @@
+package trending
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// gravity is how steeply scores fall with the age of an item.
+	gravity = 1.5
+	// halfLife is the age at which an hourly click counter counts half.
+	halfLife = 3 * time.Hour
+	// totalWeight is what an older click, only seen in the item's total,
+	// counts compared to a click in the current hour.
+	totalWeight = 0.1
+	// maxCandidates bounds the newest items ranked on each run, the recently
+	// clicked ones are ranked in addition.
+	maxCandidates = 5000
+	// batchSize is how many clicked items are read at a time.
+	batchSize = 500
+)
+
+// Entry is a ranked item.
+type Entry struct {
+	Item  rss.Item `json:"item"`
+	Score float64  `json:"score"`
+}
+
+// Lists are keyed by category enName and language, empty for all of them.
+type key struct {
+	category string
+	language string
+}
+
+// Ranker ranks the items published within Window by clicks and recency and
+// keeps the Size best of each category and language, at most PerSource of
+// them from the same source.
+type Ranker struct {
+	Store     store.Store
+	Window    time.Duration
+	Size      int
+	PerSource int
+
+	mu      sync.RWMutex
+	lists   map[key][]Entry
+	names   map[string]string
+	updated time.Time
+}
+
+func New(s store.Store, window time.Duration, size, perSource int) *Ranker {
+	return &Ranker{Store: s, Window: window, Size: size, PerSource: perSource}
+}
+
+func (r *Ranker) Run(d time.Duration) {
+	for ; ; <-time.After(d) {
+		if err := r.Refresh(context.Background()); err != nil {
+			log.Println("ranking trending items failed", err)
+		}
+	}
+}
+
+// Refresh ranks the items again and replaces the lists.
+func (r *Ranker) Refresh(ctx context.Context) error {
+	now := time.Now()
+	counts, err := r.Store.ClickCounts(ctx, now.Add(-4*halfLife))
+	if err != nil {
+		return err
+	}
+	recent := make(map[primitive.ObjectID]float64)
+	for _, c := range counts {
+		recent[c.ItemID] += float64(c.Clicks) * math.Exp2(-now.Sub(c.Hour).Hours()/halfLife.Hours())
+	}
+	items, err := r.candidates(ctx, now, recent)
+	if err != nil {
+		return err
+	}
+	entries := make([]Entry, len(items))
+	for i, item := range items {
+		entries[i] = Entry{Item: item, Score: Score(item, recent[item.ID], now)}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Score > entries[j].Score })
+
+	grouped := make(map[key][]Entry)
+	names := make(map[string]string)
+	for _, e := range entries {
+		category := e.Item.Category.EnName
+		if category == "" {
+			category = e.Item.Category.Name
+		}
+		names[e.Item.Category.Name] = category
+		for _, k := range []key{{"", ""}, {category, ""}, {"", e.Item.Language}, {category, e.Item.Language}} {
+			grouped[k] = append(grouped[k], e)
+		}
+	}
+	lists := make(map[key][]Entry, len(grouped))
+	for k, candidates := range grouped {
+		lists[k] = r.diverse(candidates)
+	}
+	r.mu.Lock()
+	r.lists, r.names, r.updated = lists, names, now
+	r.mu.Unlock()
+	return nil
+}
+
+// candidates returns the newest items of the window and the ones in it
+// clicked recently, which may be older than the newest maxCandidates.
+func (r *Ranker) candidates(ctx context.Context, now time.Time, recent map[primitive.ObjectID]float64) ([]rss.Item, error) {
+	after := now.Add(-r.Window)
+	items, err := r.Store.FindItems(ctx, store.ItemQuery{After: after, Limit: maxCandidates})
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[primitive.ObjectID]bool, len(items))
+	for _, item := range items {
+		seen[item.ID] = true
+	}
+	var clicked []primitive.ObjectID
+	for id := range recent {
+		if !seen[id] {
+			clicked = append(clicked, id)
+		}
+	}
+	for len(clicked) > 0 {
+		batch := clicked[:min(len(clicked), batchSize)]
+		clicked = clicked[len(batch):]
+		found, err := r.Store.FindItems(ctx, store.ItemQuery{IDs: batch, After: after})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, found...)
+	}
+	return items, nil
+}
+
+// Score weighs the clicks of the recent hours, decayed by their age, and
+// a little of the item's total clicks against the item's age. Items
+// without clicks rank by age alone.
+func Score(item rss.Item, recent float64, now time.Time) float64 {
+	age := math.Max(now.Sub(item.Date).Hours(), 0)
+	return (1 + recent + totalWeight*float64(item.Clicks)) / math.Pow(age+2, gravity)
+}
+
+// diverse picks the best candidates, skipping sources already listed
+// PerSource times.
+func (r *Ranker) diverse(candidates []Entry) []Entry {
+	list := []Entry{}
+	perSource := make(map[string]int)
+	for _, e := range candidates {
+		if len(list) == r.Size {
+			break
+		}
+		if r.PerSource > 0 && perSource[e.Item.Source] >= r.PerSource {
+			continue
+		}
+		perSource[e.Item.Source]++
+		list = append(list, e)
+	}
+	return list
+}
+
+// Get returns the ranked items of a category, matched on its enName or
+// name, and language, both optional, and when they were ranked.
+func (r *Ranker) Get(category, language string) ([]Entry, time.Time) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if enName, ok := r.names[category]; ok {
+		category = enName
+	}
+	list := r.lists[key{category, language}]
+	if list == nil {
+		list = []Entry{}
+	}
+	return list, r.updated
+}
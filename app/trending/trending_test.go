@@ -0,0 +1,43 @@
+package trending
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/memory"
+	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/store"
+)
+
+func TestRefreshRanksClickedItemsBeyondNewest(t *testing.T) {
+	s := memory.New()
+	ctx := context.Background()
+	now := time.Now()
+	items := []rss.Item{{GUID: "clicked", Date: now.Add(-20 * time.Hour), Source: "old"}}
+	for i := 0; i < maxCandidates; i++ {
+		items = append(items, rss.Item{GUID: fmt.Sprint(i), Date: now.Add(-time.Duration(i) * time.Second), Source: fmt.Sprint(i)})
+	}
+	if _, err := s.SaveItems(ctx, items); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := s.FindItems(ctx, store.ItemQuery{Oldest: true, Limit: 1})
+	if len(stored) != 1 || stored[0].GUID != "clicked" {
+		t.Fatal("clicked item not stored", stored)
+	}
+	for i := 0; i < 1000; i++ {
+		if err := s.AddClick(ctx, stored[0].ID, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := New(s, 24*time.Hour, 10, 0)
+	if err := r.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	list, _ := r.Get("", "")
+	if len(list) == 0 || list[0].Item.GUID != "clicked" {
+		t.Fatal("clicked item not ranked first", list)
+	}
+}
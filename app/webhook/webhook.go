@@ -0,0 +1,238 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/jelinden/rssfetcher/app/domain"
+	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Headers sent with every delivery. The signature is the hex encoded
+// HMAC-SHA256 of the timestamp, a dot and the body, keyed with the webhook
+// secret, so receivers can check both the sender and the freshness.
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// Payload is the JSON body of a delivery.
+type Payload struct {
+	Event     string             `json:"event"`
+	WebhookID primitive.ObjectID `json:"webhookId"`
+	Item      rss.Item           `json:"item"`
+}
+
+// Dispatcher delivers new items to the webhooks whose filters match them,
+// making up to MaxAttempts attempts, waiting Backoff after the first failed
+// one and twice as long after each next one.
+type Dispatcher struct {
+	Store       store.Store
+	Client      *http.Client
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+func New(s store.Store, maxAttempts int, backoff time.Duration) *Dispatcher {
+	return &Dispatcher{
+		Store:       s,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts: maxAttempts,
+		Backoff:     backoff,
+	}
+}
+
+// Publish starts deliveries of the inserted items to the matching webhooks.
+func (d *Dispatcher) Publish(ctx context.Context, changes []store.ItemChange) {
+	var items []rss.Item
+	for _, change := range changes {
+		if !change.Updated {
+			items = append(items, change.Item)
+		}
+	}
+	if len(items) == 0 {
+		return
+	}
+	webhooks, err := d.Store.GetWebhooks(ctx)
+	if err != nil {
+		log.Println("getting webhooks failed", err)
+		return
+	}
+	for _, webhook := range webhooks {
+		pattern, err := Compile(webhook.Filter.Pattern)
+		if err != nil {
+			log.Println("invalid webhook pattern", webhook.ID.Hex(), err)
+			continue
+		}
+		for _, item := range items {
+			if !Matches(webhook.Filter, pattern, item) {
+				continue
+			}
+			delivery, err := d.create(ctx, webhook, item)
+			if err != nil {
+				log.Println("saving webhook delivery failed", webhook.ID.Hex(), err)
+				continue
+			}
+			go d.deliver(webhook, delivery)
+		}
+	}
+}
+
+// Compile compiles a filter pattern, nil for an empty one.
+func Compile(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
+}
+
+// Matches reports whether item passes the filter, whose pattern is
+// compiled.
+func Matches(f domain.WebhookFilter, pattern *regexp.Regexp, item rss.Item) bool {
+	return (f.FeedID.IsZero() || f.FeedID == item.FeedID) &&
+		(f.Category == "" || f.Category == item.Category.EnName || f.Category == item.Category.Name) &&
+		(f.Language == "" || f.Language == item.Language) &&
+		(pattern == nil || pattern.MatchString(item.Title) || pattern.MatchString(item.Content))
+}
+
+func (d *Dispatcher) create(ctx context.Context, webhook domain.Webhook, item rss.Item) (domain.Delivery, error) {
+	now := time.Now()
+	delivery := domain.Delivery{
+		ID:        primitive.NewObjectID(),
+		WebhookID: webhook.ID,
+		ItemID:    item.ID,
+		Title:     item.Title,
+		Status:    domain.DeliveryPending,
+		Created:   now,
+		Updated:   now,
+	}
+	payload, err := json.Marshal(Payload{Event: "item.created", WebhookID: webhook.ID, Item: item})
+	if err != nil {
+		return delivery, err
+	}
+	delivery.Payload = string(payload)
+	return delivery, d.Store.SaveDelivery(ctx, delivery)
+}
+
+// Retry starts a failed delivery again with a fresh set of attempts.
+func (d *Dispatcher) Retry(ctx context.Context, id primitive.ObjectID) error {
+	delivery, err := d.Store.GetDelivery(ctx, id)
+	if err != nil {
+		return err
+	}
+	webhook, err := d.Store.GetWebhook(ctx, delivery.WebhookID)
+	if err != nil {
+		return err
+	}
+	delivery.Status = domain.DeliveryPending
+	delivery.Attempts = 0
+	delivery.Updated = time.Now()
+	if err := d.Store.SaveDelivery(ctx, *delivery); err != nil {
+		return err
+	}
+	go d.deliver(*webhook, *delivery)
+	return nil
+}
+
+// Resume restarts the deliveries left pending by a restart.
+func (d *Dispatcher) Resume(ctx context.Context) error {
+	pending, err := d.Store.FindDeliveries(ctx, store.DeliveryQuery{Status: domain.DeliveryPending})
+	if err != nil {
+		return err
+	}
+	for _, delivery := range pending {
+		webhook, err := d.Store.GetWebhook(ctx, delivery.WebhookID)
+		if err != nil {
+			log.Println("resuming webhook delivery failed", delivery.ID.Hex(), err)
+			continue
+		}
+		go d.deliver(*webhook, delivery)
+	}
+	return nil
+}
+
+// deliver makes the remaining attempts of a delivery, saving the outcome of
+// each.
+func (d *Dispatcher) deliver(webhook domain.Webhook, delivery domain.Delivery) {
+	wait := d.Backoff << max(delivery.Attempts-1, 0)
+	for delivery.Status == domain.DeliveryPending {
+		if delivery.Attempts > 0 {
+			time.Sleep(wait)
+			wait *= 2
+		}
+		delivery.Attempts++
+		status, err := d.post(webhook, delivery)
+		delivery.StatusCode = status
+		delivery.Error = ""
+		delivery.Updated = time.Now()
+		switch {
+		case err == nil:
+			delivery.Status = domain.DeliveryDelivered
+		case delivery.Attempts >= d.MaxAttempts || permanent(status):
+			delivery.Status = domain.DeliveryFailed
+			delivery.Error = err.Error()
+		default:
+			delivery.Error = err.Error()
+		}
+		if err := d.Store.SaveDelivery(context.Background(), delivery); err != nil {
+			log.Println("saving webhook delivery failed", delivery.ID.Hex(), err)
+		}
+	}
+	if delivery.Status == domain.DeliveryFailed {
+		log.Println("webhook delivery failed", delivery.ID.Hex(), webhook.URL, delivery.Error)
+	}
+}
+
+// post sends the delivery once and returns the response status.
+func (d *Dispatcher) post(webhook domain.Webhook, delivery domain.Delivery) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "rssfetcher-webhook")
+	req.Header.Set(DeliveryHeader, delivery.ID.Hex())
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, "sha256="+Sign(webhook.Secret, timestamp, body))
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature of a delivery body sent at timestamp.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// permanent reports whether retrying a response status cannot help: the
+// receiver rejected the request itself.
+func permanent(status int) bool {
+	return status >= 400 && status < 500 && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests
+}
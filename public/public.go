@@ -0,0 +1,7 @@
+// Package public holds the admin page templates, built into the binary.
+package public
+
+import "embed"
+
+//go:embed tmpl/*.html
+var Templates embed.FS
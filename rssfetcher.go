@@ -3,41 +3,217 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"regexp"
+	"strings"
 	"time"
 
-	"github.com/jelinden/rssfetcher/app/domain"
+	"github.com/jelinden/rssfetcher/app/admin"
+	"github.com/jelinden/rssfetcher/app/clicks"
+	"github.com/jelinden/rssfetcher/app/cluster"
+	"github.com/jelinden/rssfetcher/app/fetcher"
 	"github.com/jelinden/rssfetcher/app/handler"
+	"github.com/jelinden/rssfetcher/app/icon"
+	"github.com/jelinden/rssfetcher/app/jobs"
+	"github.com/jelinden/rssfetcher/app/memory"
+	"github.com/jelinden/rssfetcher/app/migrate"
 	"github.com/jelinden/rssfetcher/app/mongo"
+	"github.com/jelinden/rssfetcher/app/retention"
+	"github.com/jelinden/rssfetcher/app/rss"
+	"github.com/jelinden/rssfetcher/app/sqlite"
+	"github.com/jelinden/rssfetcher/app/store"
+	"github.com/jelinden/rssfetcher/app/stream"
+	"github.com/jelinden/rssfetcher/app/trending"
+	"github.com/jelinden/rssfetcher/app/webhook"
 )
 
 var (
-	validPath    = regexp.MustCompile("^/(edit|save|view|delete)/([a-zA-Z0-9]*)$")
-	mongoAddress = flag.String("address", "localhost", "mongo address")
-	env          = flag.String("env", "dev", "environment")
+	validPath     = regexp.MustCompile("^/(edit|save|view|delete|icons)/([a-zA-Z0-9]*)$")
+	mongoAddress  = flag.String("address", "localhost", "mongo address")
+	storeURL      = flag.String("store", "", "storage to use: sqlite:///path/to/file.db, memory: or a mongodb:// URI, defaults to mongo at -address")
+	env           = flag.String("env", "dev", "environment")
+	mongoConfig   = flag.String("mongo-config", "", "JSON file naming the mongo database and collections")
+	database      = flag.String("db", "", "mongo database name, defaults to news")
+	feedColl      = flag.String("feed-collection", "", "mongo feed collection name")
+	newsColl      = flag.String("news-collection", "", "mongo news item collection name")
+	categoryColl  = flag.String("category-collection", "", "mongo category collection name")
+	subCatColl    = flag.String("subcategory-collection", "", "mongo subcategory collection name")
+	archiveColl   = flag.String("archive-collection", "", "mongo collection expired news items are archived to")
+	clickColl     = flag.String("click-collection", "", "mongo collection of hourly click counters")
+	webhookColl   = flag.String("webhook-collection", "", "mongo collection of webhook subscriptions")
+	deliveryColl  = flag.String("delivery-collection", "", "mongo collection of the webhook delivery log")
+	blocklistColl = flag.String("blocklist-collection", "", "mongo collection of the global blocklist")
+	iconDir       = flag.String("icons", "icons", "directory for cached feed icons")
+	retainEvery   = flag.Duration("retention-interval", time.Hour, "how often expired news items are removed")
+	retainAge     = flag.Duration("retention-max-age", 0, "how long news items are kept, 0 keeps them forever")
+	retainCats    = flag.String("retention-category-age", "", "per category max ages, e.g. \"sports=168h,economy=720h\"")
+	retainCount   = flag.Int("retention-max-per-feed", 0, "how many news items are kept per feed, 0 keeps all")
+	retainClicks  = flag.Int("retention-clicks", 0, "items with at least this many clicks are kept until -retention-clicked-age")
+	retainPopAge  = flag.Duration("retention-clicked-age", 0, "how long items with many clicks are kept, 0 keeps them forever")
+	archive       = flag.String("retention-archive", "", "where expired items are moved: \"store\" for the archive collection or a JSONL file path")
+	retainCounts  = flag.Duration("retention-click-counts", 30*24*time.Hour, "how long hourly click counters are kept, 0 keeps them forever")
+	retainLog     = flag.Duration("retention-deliveries", 14*24*time.Hour, "how long the webhook delivery log is kept, 0 keeps it forever")
+	hookAttempts  = flag.Int("webhook-attempts", 5, "how many times a webhook delivery is tried before it fails")
+	hookBackoff   = flag.Duration("webhook-backoff", 30*time.Second, "wait after a failed webhook delivery, doubled after each next failure")
+	clusterAge    = flag.Duration("cluster-window", 48*time.Hour, "time window within which items are clustered into stories, 0 disables clustering")
+	clusterBits   = flag.Int("cluster-distance", 10, "max differing title fingerprint bits for items of the same story")
+	migration     = flag.String("migrate", "", "run the named data migration and exit")
+	clickWindow   = flag.Duration("click-window", 30*time.Minute, "repeated clicks on an item from one address within this window count once")
+	behindProxy   = flag.Bool("behind-proxy", false, "take client addresses for click counting from X-Forwarded-For")
+	trendEvery    = flag.Duration("trending-interval", 5*time.Minute, "how often trending items are ranked, 0 disables ranking")
+	trendWindow   = flag.Duration("trending-window", 48*time.Hour, "how old items can be to trend")
+	trendSize     = flag.Int("trending-size", 50, "how many trending items are kept per category and language")
+	trendSources  = flag.Int("trending-per-source", 3, "how many trending items of a list may come from one source, 0 for no limit")
+	streamSize    = flag.Int("stream-buffer", 1000, "how many item events are kept for clients resuming /api/v1/stream")
+	tracking      = flag.String("tracking-params", "", "comma separated query parameters stripped from canonical links, \"utm_*\" matches a prefix")
 )
 
 func main() {
 	flag.Parse()
-	mongoRepository := mongo.MongoRepository{}
-	mongoRepository.Client = mongo.InitMongoClient(*mongoAddress)
-	mongo.MongoClient = mongoRepository
-	defer mongo.MongoClient.Client.Disconnect(context.Background())
-	runFeedFetcher(*env)
-	flag.Parse()
-	http.HandleFunc("/view/", makeHandler(handler.ViewHandler))
-	http.HandleFunc("/delete/", makeHandler(handler.RemoveHandler))
-	http.HandleFunc("/edit/", makeHandler(handler.EditHandler))
-	http.HandleFunc("/save/", makeHandler(handler.SaveHandler))
-	http.HandleFunc("/save/category", makeHandler(handler.SaveCategoryHandler))
-	http.HandleFunc("/save/subcategory", makeHandler(handler.SaveSubCategoryHandler))
+	if params := rss.ParseTrackingParams(*tracking); len(params) > 0 {
+		rss.TrackingParams = params
+	}
+	s, err := openStore(*storeURL)
+	if err != nil {
+		log.Fatal("opening store failed ", err)
+	}
+	defer s.Close(context.Background())
+	if *migration != "" {
+		if err := migrate.Run(context.Background(), s, *migration); err != nil {
+			log.Fatal("migration failed ", err)
+		}
+		log.Println("migration", *migration, "done")
+		return
+	}
+	icon.Dir = *iconDir
+	f := fetcher.New(s)
+	f.Stream = stream.New(*streamSize)
+	f.Webhooks = webhook.New(s, *hookAttempts, *hookBackoff)
+	if err := f.Webhooks.Resume(context.Background()); err != nil {
+		log.Println("resuming webhook deliveries failed", err)
+	}
+	if *clusterAge > 0 {
+		f.Cluster = cluster.New(s, *clusterAge, *clusterBits)
+	}
+	runFeedFetcher(*env, f)
+	go refreshIcons(s, time.Hour)
+	if err := runRetention(s); err != nil {
+		log.Fatal("retention config failed ", err)
+	}
+	a := admin.New(s, jobs.NewRunner())
+	a.Webhooks = f.Webhooks
+	h := handler.New(s, a)
+	h.Clicks = clicks.New(s, *clickWindow)
+	h.BehindProxy = *behindProxy
+	h.Stream = f.Stream
+	if *trendEvery > 0 {
+		h.Trending = trending.New(s, *trendWindow, *trendSize, *trendSources)
+		go h.Trending.Run(*trendEvery)
+	}
+	http.HandleFunc("/view/", makeHandler(h.ViewHandler))
+	http.HandleFunc("/delete/", makeHandler(h.RemoveHandler))
+	http.HandleFunc("/edit/", makeHandler(h.EditHandler))
+	http.HandleFunc("/save/", makeHandler(h.SaveHandler))
+	http.HandleFunc("/save/category", makeHandler(h.SaveCategoryHandler))
+	http.HandleFunc("/save/subcategory", makeHandler(h.SaveSubCategoryHandler))
+	http.HandleFunc("/icons/", makeHandler(h.IconHandler))
+	http.HandleFunc("GET /api/v1/clusters/{id}", h.ClusterHandler)
+	http.HandleFunc("GET /category/{id}", h.EditCategoryHandler)
+	http.HandleFunc("POST /category/{id}", h.UpdateCategoryHandler)
+	http.HandleFunc("POST /category/{id}/delete", h.DeleteCategoryHandler)
+	http.HandleFunc("GET /subcategory/{id}", h.EditSubCategoryHandler)
+	http.HandleFunc("POST /subcategory/{id}", h.UpdateSubCategoryHandler)
+	http.HandleFunc("POST /subcategory/{id}/delete", h.DeleteSubCategoryHandler)
+	http.HandleFunc("GET /api/v1/feeds", h.ListFeedsHandler)
+	http.HandleFunc("POST /api/v1/feeds", h.CreateFeedHandler)
+	http.HandleFunc("GET /api/v1/feeds/{id}", h.GetFeedHandler)
+	http.HandleFunc("PUT /api/v1/feeds/{id}", h.UpdateFeedHandler)
+	http.HandleFunc("DELETE /api/v1/feeds/{id}", h.DeleteFeedHandler)
+	http.HandleFunc("GET /api/v1/categories", h.ListCategoriesHandler)
+	http.HandleFunc("POST /api/v1/categories", h.CreateCategoryHandler)
+	http.HandleFunc("GET /api/v1/categories/{id}", h.GetCategoryHandler)
+	http.HandleFunc("PUT /api/v1/categories/{id}", h.UpdateCategoryAPIHandler)
+	http.HandleFunc("DELETE /api/v1/categories/{id}", h.DeleteCategoryAPIHandler)
+	http.HandleFunc("GET /api/v1/subcategories", h.ListSubCategoriesHandler)
+	http.HandleFunc("POST /api/v1/subcategories", h.CreateSubCategoryHandler)
+	http.HandleFunc("GET /api/v1/subcategories/{id}", h.GetSubCategoryHandler)
+	http.HandleFunc("PUT /api/v1/subcategories/{id}", h.UpdateSubCategoryAPIHandler)
+	http.HandleFunc("DELETE /api/v1/subcategories/{id}", h.DeleteSubCategoryAPIHandler)
+	http.HandleFunc("GET /api/v1/jobs/{id}", h.JobHandler)
+	http.HandleFunc("GET /api/v1/items", h.ItemsHandler)
+	http.HandleFunc("GET /api/v1/trending", h.TrendingHandler)
+	http.HandleFunc("GET /api/v1/stream", h.StreamHandler)
+	http.HandleFunc("GET /webhooks", h.WebhooksHandler)
+	http.HandleFunc("POST /webhooks", h.CreateWebhookHandler)
+	http.HandleFunc("GET /webhooks/{id}", h.EditWebhookHandler)
+	http.HandleFunc("POST /webhooks/{id}", h.UpdateWebhookHandler)
+	http.HandleFunc("POST /webhooks/{id}/delete", h.DeleteWebhookHandler)
+	http.HandleFunc("POST /deliveries/{id}/retry", h.RetryDeliveryHandler)
+	http.HandleFunc("GET /blocklist", h.BlocklistHandler)
+	http.HandleFunc("POST /blocklist", h.AddBlockRuleHandler)
+	http.HandleFunc("POST /blocklist/{id}/delete", h.DeleteBlockRuleHandler)
+	http.HandleFunc("GET /feeds/{file}", h.PublishHandler)
+	http.HandleFunc("GET /go/{id}", h.GoHandler)
 
 	log.Println("Starting up at port 9200")
 	log.Fatal(http.ListenAndServe(":9200", nil))
 }
 
+func openStore(url string) (store.Store, error) {
+	switch {
+	case url == "":
+		config, err := loadMongoConfig()
+		if err != nil {
+			return nil, err
+		}
+		return openMongo(*mongoAddress, config)
+	case strings.HasPrefix(url, "sqlite://"):
+		return sqlite.Open(strings.TrimPrefix(url, "sqlite://"))
+	case url == "memory:":
+		return memory.New(), nil
+	case strings.HasPrefix(url, "mongodb://"), strings.HasPrefix(url, "mongodb+srv://"):
+		config, err := loadMongoConfig()
+		if err != nil {
+			return nil, err
+		}
+		return openMongo(url, config)
+	}
+	return nil, fmt.Errorf("unknown store %q", url)
+}
+
+func openMongo(address string, config mongo.Config) (store.Store, error) {
+	repository, err := mongo.NewMongoRepository(address, config)
+	if err != nil {
+		return nil, err
+	}
+	return repository, nil
+}
+
+// loadMongoConfig reads the database and collection names from the config
+// file, then the environment and finally the flags, later ones winning.
+func loadMongoConfig() (mongo.Config, error) {
+	config, err := mongo.LoadConfig(*mongoConfig)
+	if err != nil {
+		return config, err
+	}
+	config.ApplyEnv()
+	config.Override(mongo.Config{
+		Database:      *database,
+		Feeds:         *feedColl,
+		News:          *newsColl,
+		Categories:    *categoryColl,
+		SubCategories: *subCatColl,
+		Archive:       *archiveColl,
+		Clicks:        *clickColl,
+		Webhooks:      *webhookColl,
+		Deliveries:    *deliveryColl,
+		Blocklist:     *blocklistColl,
+	})
+	return config, nil
+}
+
 func makeHandler(fn func(http.ResponseWriter, *http.Request)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		m := validPath.FindStringSubmatch(r.URL.Path)
@@ -49,17 +225,51 @@ func makeHandler(fn func(http.ResponseWriter, *http.Request)) http.HandlerFunc {
 	}
 }
 
-func runFeedFetcher(env string) {
+func runFeedFetcher(env string, f *fetcher.Fetcher) {
 	if env == "dev" {
-		go doEvery(60*time.Second, mongo.GetFeeds)
+		go f.Run(60 * time.Second)
 	} else {
-		go doEvery(80*time.Second, mongo.GetFeeds)
+		go f.Run(80 * time.Second)
 	}
 }
 
-func doEvery(d time.Duration, feeds func(args ...bool) []domain.Feed) {
-	for _ = range time.Tick(d) {
-		feedList := feeds(true)
-		mongo.GetNews(feedList)
+func runRetention(s store.Store) error {
+	ages, err := retention.ParseCategoryAges(*retainCats)
+	if err != nil {
+		return err
+	}
+	policy := retention.Policy{
+		MaxAge:          *retainAge,
+		CategoryMaxAge:  ages,
+		MaxItemsPerFeed: *retainCount,
+		ClickThreshold:  *retainClicks,
+		ClickedMaxAge:   *retainPopAge,
+		ClickCountAge:   *retainCounts,
+		DeliveryAge:     *retainLog,
+	}
+	if policy.MaxAge == 0 && len(ages) == 0 && policy.MaxItemsPerFeed == 0 && policy.ClickedMaxAge == 0 &&
+		policy.ClickCountAge == 0 && policy.DeliveryAge == 0 {
+		return nil
+	}
+	var archiver retention.Archiver
+	switch *archive {
+	case "":
+	case "store":
+		archiver = retention.StoreArchiver{Store: s}
+	default:
+		archiver = &retention.FileArchiver{Path: *archive}
+	}
+	go retention.New(s, policy, archiver).Run(*retainEvery)
+	return nil
+}
+
+func refreshIcons(s store.Store, d time.Duration) {
+	for ; ; <-time.After(d) {
+		feeds, err := s.GetFeeds(context.Background(), true)
+		if err != nil {
+			log.Println("getting feeds failed", err)
+			continue
+		}
+		icon.RefreshAll(feeds)
 	}
 }